@@ -1,13 +1,75 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
 )
 
 func main() {
+	preset := flag.String("preset", "", "preset log format name: "+strings.Join(goaccessfmt.PresetNames(), ", "))
+	logfmt := flag.String("format", "", "log format string")
+	datefmt := flag.String("date-format", "", "date format, overrides that of the preset")
+	timefmt := flag.String("time-format", "", "time format, overrides that of the preset")
+	tz := flag.String("tz", "UTC", "timezone of the log")
+	quiet := flag.Bool("quiet", false, "do not report lines that fail to parse")
+	flag.Parse()
+
+	if *preset == "" && *logfmt == "" {
+		demo()
+		return
+	}
+	if err := ingest(*preset, *logfmt, *datefmt, *timefmt, *tz, *quiet); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// ingest reads log lines from stdin and writes them to stdout as NDJSON.
+func ingest(preset, logfmt, datefmt, timefmt, tz string, quiet bool) error {
+	if preset != "" {
+		l, d, t, err := goaccessfmt.GetFmtFromPreset(preset)
+		if err != nil {
+			return fmt.Errorf("unknown preset %q", preset)
+		}
+		if logfmt == "" {
+			logfmt = l
+		}
+		if datefmt == "" {
+			datefmt = d
+		}
+		if timefmt == "" {
+			timefmt = t
+		}
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return err
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, location)
+	if err != nil {
+		return err
+	}
+	if preset != "" {
+		conf.FormatName = preset
+		conf.ApacheErrorLog = strings.EqualFold(preset, "apache_error")
+	}
+
+	var opts goaccessfmt.StreamOptions
+	if !quiet {
+		opts.OnError = func(line string, err error) {
+			fmt.Fprintf(os.Stderr, "%v: %s\n", err, line)
+		}
+	}
+	return goaccessfmt.WriteNDJSON(os.Stdout, os.Stdin, conf, opts)
+}
+
+// demo parses a few sample lines and prints the results.
+func demo() {
 	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("combined")
 	if err != nil {
 		panic(err)
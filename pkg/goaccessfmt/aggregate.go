@@ -0,0 +1,35 @@
+package goaccessfmt
+
+import "io"
+
+// OtherHosts is the AggregateByHost key for hosts beyond the cap.
+const OtherHosts = "(other)"
+
+// HostStats is the traffic of one host summed by AggregateByHost.
+type HostStats struct {
+	Requests uint64
+	Bytes    uint64
+}
+
+// AggregateByHost parses each line read from r with conf and sums the
+// requests and RespSize per Host. Lines that fail to parse are skipped. If
+// maxHosts is positive, at most maxHosts hosts are tracked, and the traffic
+// of any other host is summed under OtherHosts.
+func AggregateByHost(conf Config, r io.Reader, maxHosts int) (map[string]HostStats, error) {
+	stats := make(map[string]HostStats)
+	err := streamLines(r, conf, StreamOptions{}, func(logitem *GLogItem) error {
+		host := logitem.Host
+		if _, ok := stats[host]; !ok && maxHosts > 0 && len(stats) >= maxHosts {
+			host = OtherHosts
+		}
+		s := stats[host]
+		s.Requests++
+		s.Bytes += logitem.RespSize
+		stats[host] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,48 @@
+package goaccessfmt_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestAggregateByHost(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "POST /b HTTP/1.1" 404 12
+invalid
+114.5.1.4 - - [11/Jun/2023:11:23:47 +0000] "GET /c HTTP/1.1" 200 100
+114.5.1.6 - - [11/Jun/2023:11:23:48 +0000] "GET /d HTTP/1.1" 200 1
+`
+	stats, err := goaccessfmt.AggregateByHost(conf, strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]goaccessfmt.HostStats{
+		"114.5.1.4": {Requests: 2, Bytes: 668},
+		"114.5.1.5": {Requests: 1, Bytes: 12},
+		"114.5.1.6": {Requests: 1, Bytes: 1},
+	}
+	if len(stats) != len(want) {
+		t.Errorf("want (%v), get (%v)", want, stats)
+	}
+	for host, s := range want {
+		if stats[host] != s {
+			t.Errorf("%s: want (%v), get (%v)", host, s, stats[host])
+		}
+	}
+
+	stats, err = goaccessfmt.AggregateByHost(conf, strings.NewReader(input), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 || stats[goaccessfmt.OtherHosts] != (goaccessfmt.HostStats{Requests: 1, Bytes: 1}) {
+		t.Errorf("want 2 hosts and (%v) other, get (%v)", goaccessfmt.HostStats{Requests: 1, Bytes: 1}, stats)
+	}
+}
@@ -0,0 +1,91 @@
+package goaccessfmt
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Apache error log lines look like
+//
+//	[Wed Oct 11 14:32:52.123456 2023] [core:error] [pid 1234:tid 5678] [client 1.2.3.4:56] AH00126: message
+//
+// where the tid and client fields are optional, and Apache 2.2 logs the
+// level without module (e.g. "[error]"). They are parsed with a dedicated
+// parser when Config.ApacheErrorLog is set.
+
+// apacheErrorTime is the layout of the timestamp. time.Parse accepts
+// the fractional seconds logged by Apache 2.4 without it being in the layout.
+const apacheErrorTime = "Mon Jan _2 15:04:05 2006"
+
+// apacheErrorPreset is the name of the preset setting Config.ApacheErrorLog.
+const apacheErrorPreset = "apache_error"
+
+// parseApacheErrorLine fills logitem from an Apache error log line: Dt,
+// Level, Host and Port (client address) and Message.
+func parseApacheErrorLine(conf Config, line string, logitem *GLogItem) error {
+	var fields []string
+	rest := strings.TrimSpace(line)
+	for strings.HasPrefix(rest, "[") {
+		end := closingBracket(rest)
+		if end == -1 {
+			return errors.New("unterminated '[' in Apache error log line")
+		}
+		fields = append(fields, rest[1:end])
+		rest = strings.TrimLeft(rest[end+1:], " ")
+	}
+	if len(fields) < 2 {
+		return errors.New("missing timestamp or level in Apache error log line")
+	}
+
+	dt, err := time.ParseInLocation(apacheErrorTime, fields[0], &conf.Timezone)
+	if err != nil {
+		return err
+	}
+	logitem.Dt = dt
+
+	level := fields[1]
+	if idx := strings.LastIndexByte(level, ':'); idx != -1 {
+		// "module:level"
+		level = level[idx+1:]
+	}
+	logitem.Level = level
+
+	for _, field := range fields[2:] {
+		if client, ok := strings.CutPrefix(field, "client "); ok {
+			host, port := stripClientPort(client)
+			logitem.Host = string(conf.transform('h', []byte(host)))
+			logitem.Port = port
+		}
+	}
+	logitem.Message = rest
+	return nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' at the start
+// of s, skipping nested brackets (e.g. "[client [::1]:56]"), or -1.
+func closingBracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// stripClientPort removes the port from an Apache client address, which is
+// "1.2.3.4:56" or "[::1]:56", and returns it. Apache 2.2 logs no port.
+func stripClientPort(client string) (string, int) {
+	if addrPort, err := netip.ParseAddrPort(client); err == nil {
+		return addrPort.Addr().String(), int(addrPort.Port())
+	}
+	return client, 0
+}
@@ -0,0 +1,97 @@
+package goaccessfmt_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestApacheError(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("apache_error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ApacheErrorLog = true
+
+	tests := []struct {
+		line string
+		want goaccessfmt.GLogItem
+	}{
+		{
+			`[Wed Oct 11 14:32:52.123456 2023] [core:error] [pid 1234:tid 140000] [client 1.2.3.4:56] AH00126: Invalid URI in request GET /%%% HTTP/1.1`,
+			goaccessfmt.GLogItem{
+				Host:    "1.2.3.4",
+				Port:    56,
+				Dt:      time.Date(2023, 10, 11, 14, 32, 52, 123456000, time.UTC),
+				Level:   "error",
+				Message: "AH00126: Invalid URI in request GET /%%% HTTP/1.1",
+			},
+		},
+		{
+			`[Wed Oct 11 14:32:52.123 2023] [proxy:warn] [pid 1234] [client [2001:db8::1]:443] AH01144: No protocol handler`,
+			goaccessfmt.GLogItem{
+				Host:    "2001:db8::1",
+				Port:    443,
+				Dt:      time.Date(2023, 10, 11, 14, 32, 52, 123000000, time.UTC),
+				Level:   "warn",
+				Message: "AH01144: No protocol handler",
+			},
+		},
+		{
+			// Apache 2.2, without module, pid and client port
+			`[Mon Oct  2 08:01:02 2023] [notice] Apache/2.2.34 configured -- resuming normal operations`,
+			goaccessfmt.GLogItem{
+				Dt:      time.Date(2023, 10, 2, 8, 1, 2, 0, time.UTC),
+				Level:   "notice",
+				Message: "Apache/2.2.34 configured -- resuming normal operations",
+			},
+		},
+	}
+	for _, test := range tests {
+		logitem, err := goaccessfmt.ParseLine(conf, test.line)
+		if err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		test.want.Status = -1
+		if !logitem.Equal(test.want) {
+			t.Errorf("want (%v), get (%v)", test.want, logitem)
+		}
+	}
+
+	if _, err := goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568`); err == nil {
+		t.Error("want error for an access log line")
+	}
+}
+
+func TestApacheErrorConffile(t *testing.T) {
+	conf, err := goaccessfmt.ParseConfigReader(strings.NewReader("preset apache_error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conf.ApacheErrorLog {
+		t.Fatal("want ApacheErrorLog set by the apache_error preset")
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, `[Wed Oct 11 14:32:52 2023] [error] [client 1.2.3.4] File does not exist`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Level != "error" || logitem.Message != "File does not exist" {
+		t.Errorf("want (error, File does not exist), get (%v, %v)", logitem.Level, logitem.Message)
+	}
+
+	// the former sentinel format is an ordinary format now
+	conf, err = goaccessfmt.SetupConfig("APACHE_ERROR", "", "", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.ApacheErrorLog {
+		t.Error("want ApacheErrorLog unset for a log format")
+	}
+}
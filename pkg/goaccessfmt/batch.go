@@ -0,0 +1,73 @@
+package goaccessfmt
+
+import "time"
+
+// RecordBatch accumulates parsed log items column by column, so that
+// downstream consumers can convert them to a columnar format cheaply.
+// All slices always have the same length.
+type RecordBatch struct {
+	Agents        []string
+	Hosts         []string
+	Methods       []string
+	Protocols     []string
+	Qstrs         []string
+	Refs          []string
+	Reqs          []string
+	Statuses      []int
+	VHosts        []string
+	Userids       []string
+	CacheStatuses []string
+
+	RespSizes  []uint64
+	ServeTimes []uint64
+
+	MimeTypes  []string
+	TLSTypes   []string
+	TLSCyphers []string
+
+	Servers      []string
+	RequestIDs   []string
+	GeoLocations []string
+
+	Times []time.Time
+}
+
+// Len returns the number of records in the batch.
+func (b *RecordBatch) Len() int {
+	return len(b.Hosts)
+}
+
+// Append adds the fields of logitem as a new row.
+func (b *RecordBatch) Append(logitem *GLogItem) {
+	b.Agents = append(b.Agents, logitem.Agent)
+	b.Hosts = append(b.Hosts, logitem.Host)
+	b.Methods = append(b.Methods, logitem.Method)
+	b.Protocols = append(b.Protocols, logitem.Protocol)
+	b.Qstrs = append(b.Qstrs, logitem.Qstr)
+	b.Refs = append(b.Refs, logitem.Ref)
+	b.Reqs = append(b.Reqs, logitem.Req)
+	b.Statuses = append(b.Statuses, logitem.Status)
+	b.VHosts = append(b.VHosts, logitem.VHost)
+	b.Userids = append(b.Userids, logitem.Userid)
+	b.CacheStatuses = append(b.CacheStatuses, logitem.CacheStatus)
+	b.RespSizes = append(b.RespSizes, logitem.RespSize)
+	b.ServeTimes = append(b.ServeTimes, logitem.ServeTime)
+	b.MimeTypes = append(b.MimeTypes, logitem.MimeType)
+	b.TLSTypes = append(b.TLSTypes, logitem.TLSType)
+	b.TLSCyphers = append(b.TLSCyphers, logitem.TLSCypher)
+	b.Servers = append(b.Servers, logitem.Server)
+	b.RequestIDs = append(b.RequestIDs, logitem.RequestID)
+	b.GeoLocations = append(b.GeoLocations, logitem.GeoLocation)
+	b.Times = append(b.Times, logitem.Dt)
+}
+
+// AppendFromLine parses line with conf and appends the result as a new row.
+// On error, the batch is left unchanged.
+func (b *RecordBatch) AppendFromLine(conf Config, line string) error {
+	logitem, err := ParseLine(conf, line)
+	if err != nil {
+		return err
+	}
+	b.Append(logitem)
+	return nil
+}
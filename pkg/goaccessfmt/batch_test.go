@@ -0,0 +1,51 @@
+package goaccessfmt_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestRecordBatch(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := []string{
+		`114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /a HTTP/1.1" 200 568`,
+		`114.5.1.5 - - [11/Jun/2023:11:23:46 +0800] "POST /b HTTP/1.1" 404 12`,
+		`invalid`,
+		`114.5.1.6 - - [11/Jun/2023:11:23:47 +0800] "HEAD /c HTTP/2" 304 0`,
+	}
+	var batch goaccessfmt.RecordBatch
+	for _, line := range lines {
+		_ = batch.AppendFromLine(conf, line)
+	}
+
+	if batch.Len() != 3 {
+		t.Fatalf("want 3 rows, get %d", batch.Len())
+	}
+	if want := []string{"114.5.1.4", "114.5.1.5", "114.5.1.6"}; !slices.Equal(batch.Hosts, want) {
+		t.Errorf("want (%v), get (%v)", want, batch.Hosts)
+	}
+	if want := []int{200, 404, 304}; !slices.Equal(batch.Statuses, want) {
+		t.Errorf("want (%v), get (%v)", want, batch.Statuses)
+	}
+	if want := []uint64{568, 12, 0}; !slices.Equal(batch.RespSizes, want) {
+		t.Errorf("want (%v), get (%v)", want, batch.RespSizes)
+	}
+	if want := []string{"GET", "POST", "HEAD"}; !slices.Equal(batch.Methods, want) {
+		t.Errorf("want (%v), get (%v)", want, batch.Methods)
+	}
+	want := time.Date(2023, 6, 11, 11, 23, 47, 0, locationP8)
+	if !batch.Times[2].Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, batch.Times[2])
+	}
+}
@@ -0,0 +1,115 @@
+package goaccessfmt
+
+import "strings"
+
+// FormatBuilder builds a log format string field by field. Fields are
+// separated by a space, unless a literal added with Lit comes in between, in
+// which case the literal is used verbatim. For example, the combined format
+// is built by
+//
+//	NewFormatBuilder().Host().Skip().Lit("[").Date().Lit(":").Time().Skip().Lit("] ").
+//		Quoted().Request().Status().Bytes().Quoted().Referer().Quoted().Agent().Build()
+type FormatBuilder struct {
+	sb        strings.Builder
+	lastField bool
+	quoted    bool
+}
+
+// NewFormatBuilder returns an empty FormatBuilder.
+func NewFormatBuilder() *FormatBuilder {
+	return &FormatBuilder{}
+}
+
+// Spec adds a field with the given specifier character, e.g. 'h' for "%h".
+func (b *FormatBuilder) Spec(spec byte) *FormatBuilder {
+	if b.lastField {
+		b.sb.WriteByte(' ')
+	}
+	if b.quoted {
+		b.sb.WriteByte('"')
+	}
+	b.sb.WriteByte('%')
+	b.sb.WriteByte(spec)
+	if b.quoted {
+		b.sb.WriteByte('"')
+	}
+	b.lastField = true
+	b.quoted = false
+	return b
+}
+
+// Lit adds literal text, which replaces the space between fields.
+func (b *FormatBuilder) Lit(s string) *FormatBuilder {
+	b.sb.WriteString(s)
+	b.lastField = false
+	return b
+}
+
+// Quoted makes the next field enclosed in double quotes.
+func (b *FormatBuilder) Quoted() *FormatBuilder {
+	b.quoted = true
+	return b
+}
+
+// Skip adds a field that is ignored (%^).
+func (b *FormatBuilder) Skip() *FormatBuilder { return b.Spec('^') }
+
+// Host, VHost and Userid add the client IP address, the virtual host and
+// the authenticated user (%h, %v, %e).
+func (b *FormatBuilder) Host() *FormatBuilder   { return b.Spec('h') }
+func (b *FormatBuilder) VHost() *FormatBuilder  { return b.Spec('v') }
+func (b *FormatBuilder) Userid() *FormatBuilder { return b.Spec('e') }
+
+// Date, Time and Timestamp add the date, the time, and a full timestamp
+// in the date and time formats (%d, %t, %x).
+func (b *FormatBuilder) Date() *FormatBuilder      { return b.Spec('d') }
+func (b *FormatBuilder) Time() *FormatBuilder      { return b.Spec('t') }
+func (b *FormatBuilder) Timestamp() *FormatBuilder { return b.Spec('x') }
+
+// Request adds the request line (%r). Method, URL, Query and Protocol add
+// its parts as separate fields (%m, %U, %q, %H).
+func (b *FormatBuilder) Request() *FormatBuilder  { return b.Spec('r') }
+func (b *FormatBuilder) Method() *FormatBuilder   { return b.Spec('m') }
+func (b *FormatBuilder) URL() *FormatBuilder      { return b.Spec('U') }
+func (b *FormatBuilder) Query() *FormatBuilder    { return b.Spec('q') }
+func (b *FormatBuilder) Protocol() *FormatBuilder { return b.Spec('H') }
+
+// Status, Bytes and ReqBytes add the status code and the sizes of the
+// response and of the request (%s, %b, %B).
+func (b *FormatBuilder) Status() *FormatBuilder   { return b.Spec('s') }
+func (b *FormatBuilder) Bytes() *FormatBuilder    { return b.Spec('b') }
+func (b *FormatBuilder) ReqBytes() *FormatBuilder { return b.Spec('B') }
+
+// Referer, Agent, CacheStatus, MimeType, TLSCypher and TLSType add the
+// referer, the user agent, the cache status, the MIME type of the response,
+// and the TLS cipher and version (%R, %u, %C, %M, %k, %K).
+func (b *FormatBuilder) Referer() *FormatBuilder     { return b.Spec('R') }
+func (b *FormatBuilder) Agent() *FormatBuilder       { return b.Spec('u') }
+func (b *FormatBuilder) CacheStatus() *FormatBuilder { return b.Spec('C') }
+func (b *FormatBuilder) MimeType() *FormatBuilder    { return b.Spec('M') }
+func (b *FormatBuilder) TLSCypher() *FormatBuilder   { return b.Spec('k') }
+func (b *FormatBuilder) TLSType() *FormatBuilder     { return b.Spec('K') }
+
+// Server, RequestID, TraceID and GeoLocation add the extension specifiers
+// %S, %i, %I and %g.
+func (b *FormatBuilder) Server() *FormatBuilder      { return b.Spec('S') }
+func (b *FormatBuilder) RequestID() *FormatBuilder   { return b.Spec('i') }
+func (b *FormatBuilder) TraceID() *FormatBuilder     { return b.Spec('I') }
+func (b *FormatBuilder) GeoLocation() *FormatBuilder { return b.Spec('g') }
+
+// ServeTimeSecs, ServeTimeMillis, ServeTimeMicros and ServeTimeNanos add
+// the time taken to serve the request in the given unit (%T, %L, %D, %n).
+func (b *FormatBuilder) ServeTimeSecs() *FormatBuilder   { return b.Spec('T') }
+func (b *FormatBuilder) ServeTimeMillis() *FormatBuilder { return b.Spec('L') }
+func (b *FormatBuilder) ServeTimeMicros() *FormatBuilder { return b.Spec('D') }
+func (b *FormatBuilder) ServeTimeNanos() *FormatBuilder  { return b.Spec('n') }
+
+// Build returns the format string, or an error if it cannot be parsed
+// reliably.
+func (b *FormatBuilder) Build() (string, error) {
+	format := b.sb.String()
+	if err := checkAdjacentSpecifiers(format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
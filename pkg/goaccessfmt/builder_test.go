@@ -0,0 +1,38 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestFormatBuilder(t *testing.T) {
+	format, err := goaccessfmt.NewFormatBuilder().
+		Host().Skip().Lit("[").Date().Lit(":").Time().Skip().Lit("] ").
+		Quoted().Request().Status().Bytes().Quoted().Referer().Quoted().Agent().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != goaccessfmt.Logs.Combined {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.Combined, format)
+	}
+
+	format, err = goaccessfmt.NewFormatBuilder().
+		Host().Skip().Lit("[").Date().Lit(":").Time().Skip().Lit("] ").
+		Quoted().Request().Status().Bytes().Quoted().Referer().Quoted().Agent().
+		ServeTimeSecs().Quoted().RequestID().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := goaccessfmt.Logs.Combined + ` %T "%i"`
+	if format != want {
+		t.Errorf("want (%v), get (%v)", want, format)
+	}
+
+	_, err = goaccessfmt.NewFormatBuilder().Status().Lit("").Bytes().Build()
+	if err == nil {
+		t.Error("want error for adjacent specifiers, get nil")
+	}
+}
@@ -0,0 +1,160 @@
+package goaccessfmt
+
+import (
+	"errors"
+	"strings"
+)
+
+// CEF (Common Event Format) lines look like
+//
+//	CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+//
+// where Extension is a list of space separated key=value pairs. A CEF log
+// format is a template of the same shape, whose header fields and extension
+// values are specifiers, e.g.
+//
+//	CEF:%^|%^|%^|%^|%^|%^|%^|src=%h requestMethod=%m request=%U out=%b
+
+const cefPrefix = "CEF:"
+
+// cefHeaderFields is the number of '|' separated fields before the extension.
+const cefHeaderFields = 7
+
+// isCEFLogFormat determines if we have a CEF template format
+func isCEFLogFormat(fmt string) bool {
+	return strings.HasPrefix(fmt, cefPrefix)
+}
+
+// splitCEF splits a CEF line into its header fields and its extension.
+// Escaped pipes ("\|") in the header are unescaped.
+func splitCEF(line string) ([]string, string, error) {
+	if !strings.HasPrefix(line, cefPrefix) {
+		return nil, "", errors.New("not a CEF line")
+	}
+	line = strings.TrimPrefix(line, cefPrefix)
+
+	header := make([]string, 0, cefHeaderFields)
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && (line[i+1] == '|' || line[i+1] == '\\') {
+			i++
+			field.WriteByte(line[i])
+			continue
+		}
+		if c != '|' {
+			field.WriteByte(c)
+			continue
+		}
+		header = append(header, field.String())
+		field.Reset()
+		if len(header) == cefHeaderFields {
+			return header, strings.TrimRight(line[i+1:], "\r\n"), nil
+		}
+	}
+	return nil, "", errors.New("incomplete CEF header")
+}
+
+// parseCEFExtension parses the key=value pairs of a CEF extension and calls
+// the callback function for each of them.
+//
+// A value runs until the space preceding the next key. Escaped equal signs
+// ("\="), backslashes, and newlines in values are unescaped.
+func parseCEFExtension(ext string, callback callback) error {
+	// indexes of unescaped '='
+	var eqs []int
+	for i := 0; i < len(ext); i++ {
+		if ext[i] == '\\' {
+			i++
+			continue
+		}
+		if ext[i] == '=' {
+			eqs = append(eqs, i)
+		}
+	}
+
+	keyStart := 0
+	for n, eq := range eqs {
+		key := strings.TrimSpace(ext[keyStart:eq])
+		valueEnd := len(ext)
+		if n+1 < len(eqs) {
+			next := eqs[n+1]
+			sp := strings.LastIndexByte(ext[eq+1:next], ' ')
+			if sp == -1 {
+				return errors.New("missing space between CEF extension pairs")
+			}
+			valueEnd = eq + 1 + sp
+			keyStart = valueEnd + 1
+		}
+		if key == "" {
+			return errors.New("empty CEF extension key")
+		}
+		if err := callback(key, unescapeCEFValue(ext[eq+1:valueEnd])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unescapeCEFValue(src string) string {
+	if !strings.Contains(src, "\\") {
+		return src
+	}
+	var dest strings.Builder
+	dest.Grow(len(src))
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' || i+1 >= len(src) {
+			dest.WriteByte(src[i])
+			continue
+		}
+		i++
+		switch src[i] {
+		case 'n':
+			dest.WriteByte('\n')
+		case 'r':
+			dest.WriteByte('\r')
+		default:
+			dest.WriteByte(src[i])
+		}
+	}
+	return dest.String()
+}
+
+// setupCEF fills the header and extension specifier maps of a CEF config.
+func setupCEF(conf *Config) error {
+	header, ext, err := splitCEF(conf.LogFormat)
+	if err != nil {
+		return err
+	}
+	conf.cefHeader = header
+	conf.cefMap = make(map[string]string)
+	return parseCEFExtension(ext, func(key, value string) error {
+		conf.cefMap[key] = value
+		return nil
+	})
+}
+
+func parseCEFFormat(conf Config, line string, logitem *GLogItem) error {
+	header, ext, err := splitCEF(line)
+	if err != nil {
+		return err
+	}
+	for i, spec := range conf.cefHeader {
+		if len(header[i]) == 0 || spec == "%^" {
+			continue
+		}
+		if err := parseFormat(conf, header[i], logitem, spec); err != nil {
+			return err
+		}
+	}
+	return parseCEFExtension(ext, func(key, value string) error {
+		if len(value) == 0 {
+			return nil
+		}
+		spec, exists := conf.cefMap[key]
+		if !exists {
+			return nil
+		}
+		return parseFormat(conf, value, logitem, spec)
+	})
+}
@@ -0,0 +1,36 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestCEF(t *testing.T) {
+	logfmt := `CEF:%^|%^|%S|%^|%^|%^|%^|rt=%x src=%h dhost=%v requestMethod=%m request=%U requestClientApplication=%u out=%b cn1=%s`
+	conf, err := goaccessfmt.SetupConfig(logfmt, "%*", "%*", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `CEF:0|Example|WAF\|Edge|1.0|100|Access|3|rt=1686482625000 src=10.0.0.1 dhost=example.com requestMethod=GET request=/search?q\=a b requestClientApplication=Mozilla/5.0 (X11; Linux x86_64) out=1024 cn1=200 cs1Label=rule`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "10.0.0.1",
+		Dt:       time.Date(2023, 6, 11, 11, 23, 45, 0, locationUTC),
+		VHost:    "example.com",
+		Method:   "GET",
+		Req:      "/search?q=a b",
+		Agent:    "Mozilla/5.0 (X11; Linux x86_64)",
+		Status:   200,
+		RespSize: 1024,
+		Server:   "WAF|Edge",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
@@ -3,51 +3,100 @@ package goaccessfmt
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// ParseConfigFile reads a config file from path, see ParseConfigReader.
+func ParseConfigFile(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	conf, err := ParseConfigReader(f)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf, nil
+}
+
 func ParseConfigReader(r io.Reader) (Config, error) {
 	scanner := bufio.NewScanner(r)
 
 	timeFormat := ""
 	dateFormat := ""
 	logFormat := ""
+	preset := ""
 	tz := ""
 	doubleDecode := false
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "time-format ") {
-			timeFormat = strings.TrimSpace(strings.TrimPrefix(line, "time-format "))
-		} else if strings.HasPrefix(line, "date-format ") {
-			dateFormat = strings.TrimSpace(strings.TrimPrefix(line, "date-format "))
-		} else if strings.HasPrefix(line, "log-format") {
-			logFormat = strings.TrimSpace(strings.TrimPrefix(line, "log-format "))
-		} else if strings.HasPrefix(line, "tz ") {
-			tz = strings.TrimSpace(strings.TrimPrefix(line, "tz "))
-		} else if strings.HasPrefix(line, "double-decode ") {
-			dd := strings.TrimSpace(strings.TrimPrefix(line, "double-decode "))
-			if dd == "false" {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			// blank line or comment
+			continue
+		}
+		key, value := splitConfigLine(line)
+		switch key {
+		case "time-format":
+			timeFormat = value
+		case "date-format":
+			dateFormat = value
+		case "log-format":
+			logFormat = value
+		case "preset":
+			preset = value
+		case "tz":
+			tz = value
+		case "double-decode":
+			if value == "false" {
 				doubleDecode = false
-			} else if dd == "true" {
+			} else if value == "true" {
 				doubleDecode = true
 			} else {
 				return Config{}, errors.New("double-decode value is not a boolean")
 			}
 		}
 	}
-	if logFormat == "" {
+	formatName := ""
+	if preset != "" {
+		// explicit preset, date-format and time-format override its defaults
+		if logFormat != "" {
+			return Config{}, errors.New("preset and log-format are mutually exclusive")
+		}
+		l, d, t, err := GetFmtFromPreset(preset)
+		if err != nil {
+			return Config{}, fmt.Errorf("unknown preset %q", preset)
+		}
+		formatName = strings.ToLower(preset)
+		logFormat = l
+		if dateFormat == "" {
+			dateFormat = d
+		}
+		if timeFormat == "" {
+			timeFormat = t
+		}
+	} else if logFormat == "" {
 		return Config{}, errors.New("empty log-format")
-	}
-	l, d, t, err := GetFmtFromPreset(logFormat)
-	if err == nil {
+	} else if l, d, t, err := GetFmtFromPreset(logFormat); err == nil {
+		// log-format naming a preset takes the preset's date and time
+		// formats, date-format and time-format are ignored as in goaccess
+		// (use preset to override them)
+		formatName = strings.ToLower(logFormat)
 		timeFormat = t
 		dateFormat = d
 		logFormat = l
 	} else {
+		// inline format, which requires date-format and time-format. A
+		// single word without specifiers is a mistyped preset.
+		if !strings.ContainsRune(logFormat, '%') && len(strings.Fields(logFormat)) == 1 {
+			return Config{}, fmt.Errorf("unknown log-format preset %q", logFormat)
+		}
 		if timeFormat == "" {
 			return Config{}, errors.New("empty time-format")
 		}
@@ -56,19 +105,21 @@ func ParseConfigReader(r io.Reader) (Config, error) {
 		}
 	}
 	var location *time.Location
-	if tz == "" {
+	switch strings.ToLower(tz) {
+	case "", "utc":
 		location = time.UTC
-	} else {
+	case "local", "system":
+		location = time.Local
+	default:
 		// try trim UTC prefix
-		offsetStr := strings.TrimPrefix(tz, "UTC")
-		offsetHours, err := strconv.Atoi(offsetStr)
+		offset, err := parseUTCOffset(strings.TrimPrefix(tz, "UTC"))
 		if err != nil {
 			location, err = time.LoadLocation(tz)
 			if err != nil {
 				return Config{}, err
 			}
 		} else {
-			location = time.FixedZone(tz, offsetHours*60*60)
+			location = time.FixedZone(tz, offset)
 		}
 	}
 	conf, err := SetupConfig(logFormat, dateFormat, timeFormat, location)
@@ -76,5 +127,108 @@ func ParseConfigReader(r io.Reader) (Config, error) {
 		return Config{}, err
 	}
 	conf.DoubleDecodeEnabled = doubleDecode
+	conf.FormatName = formatName
+	if p, ok := lookupPreset(formatName); ok {
+		conf.ApacheErrorLog = p.isApacheError()
+	}
 	return conf, nil
 }
+
+// WriteConfig writes conf in the syntax of ParseConfigReader: log-format
+// (or preset, for the format of FormatName), date-format, time-format, tz
+// and double-decode, so that reading it back gives the same config. Formats
+// are escaped back (e.g. a tab becomes "\t").
+func (conf Config) WriteConfig(w io.Writer) error {
+	var b strings.Builder
+	if p, ok := lookupPreset(conf.FormatName); ok && unescapeStr(p.logfmt) == conf.LogFormat && p.isApacheError() == conf.ApacheErrorLog {
+		fmt.Fprintf(&b, "preset %s\n", conf.FormatName)
+	} else if conf.ApacheErrorLog {
+		fmt.Fprintf(&b, "preset %s\n", apacheErrorPreset)
+	} else {
+		fmt.Fprintf(&b, "log-format %s\n", escapeStr(conf.LogFormat))
+	}
+	if conf.DateFormat != "" {
+		fmt.Fprintf(&b, "date-format %s\n", escapeStr(conf.DateFormat))
+	}
+	if conf.TimeFormat != "" {
+		fmt.Fprintf(&b, "time-format %s\n", escapeStr(conf.TimeFormat))
+	}
+	fmt.Fprintf(&b, "tz %s\n", timezoneName(&conf.Timezone))
+	fmt.Fprintf(&b, "double-decode %t\n", conf.DoubleDecodeEnabled)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeStr is the reverse of unescapeStr.
+func escapeStr(src string) string {
+	var dest strings.Builder
+	dest.Grow(len(src))
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			dest.WriteString(`\\`)
+		case '\n':
+			dest.WriteString(`\n`)
+		case '\r':
+			dest.WriteString(`\r`)
+		case '\t':
+			dest.WriteString(`\t`)
+		default:
+			dest.WriteByte(src[i])
+		}
+	}
+	return dest.String()
+}
+
+// timezoneName returns the tz value for loc: its name, or a "UTC+05:30"
+// offset for an unnamed fixed zone.
+func timezoneName(loc *time.Location) string {
+	if name := loc.String(); name != "" {
+		return name
+	}
+	_, offset := time.Date(2000, 1, 1, 0, 0, 0, 0, loc).Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("UTC%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// parseUTCOffset parses an offset like "+8", "-3" or "+05:30" into seconds.
+func parseUTCOffset(s string) (int, error) {
+	hoursStr, minutesStr, hasMinutes := strings.Cut(s, ":")
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil {
+		return 0, err
+	}
+	offset := hours * 60 * 60
+	if hasMinutes {
+		minutes, err := strconv.Atoi(minutesStr)
+		if err != nil || minutes < 0 || minutes >= 60 || len(minutesStr) != 2 {
+			return 0, fmt.Errorf("invalid offset minutes %q", minutesStr)
+		}
+		if strings.HasPrefix(hoursStr, "-") {
+			offset -= minutes * 60
+		} else {
+			offset += minutes * 60
+		}
+	}
+	return offset, nil
+}
+
+// splitConfigLine splits a "key value" or "key = value" line. The key is
+// lowercased.
+func splitConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:i])
+	value := strings.TrimSpace(line[i:])
+	if strings.HasPrefix(value, "=") {
+		value = strings.TrimSpace(value[1:])
+	}
+	return key, value
+}
@@ -1,6 +1,9 @@
 package goaccessfmt_test
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -30,3 +33,260 @@ tz UTC+8`
 		t.Error("timezone is not UTC+8")
 	}
 }
+
+func TestUnknownPresetConffile(t *testing.T) {
+	typoConfig := `log-format combind
+date-format %d/%b/%Y
+time-format %H:%M:%S`
+	_, err := goaccessfmt.ParseConfigReader(strings.NewReader(typoConfig))
+	if err == nil || !strings.Contains(err.Error(), "unknown log-format preset") {
+		t.Errorf("want unknown preset error, get (%v)", err)
+	}
+
+	customConfig := `log-format %h %^[%d:%t %^] "%r" %s %b
+date-format %d/%b/%Y
+time-format %H:%M:%S`
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader(customConfig))
+	if err != nil {
+		t.Error(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Common {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.Common, c.LogFormat)
+	}
+}
+
+func TestFormatNameConffile(t *testing.T) {
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader("log-format COMBINED"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FormatName != "combined" {
+		t.Errorf("want (%v), get (%v)", "combined", c.FormatName)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568 "-" "curl/8.0"`
+	logitem, err := goaccessfmt.ParseLine(c, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.FormatName != "combined" {
+		t.Errorf("want (%v), get (%v)", "combined", logitem.FormatName)
+	}
+}
+
+func TestTimezoneConffile(t *testing.T) {
+	now := time.Now()
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	cases := []struct {
+		tz  string
+		loc *time.Location
+	}{
+		{"local", time.Local},
+		{"system", time.Local},
+		{"UTC", time.UTC},
+		{"Asia/Shanghai", shanghai},
+	}
+	for _, c := range cases {
+		config := "log-format combined\ntz " + c.tz
+		conf, err := goaccessfmt.ParseConfigReader(strings.NewReader(config))
+		if err != nil {
+			t.Errorf("tz %s: %v", c.tz, err)
+			continue
+		}
+		_, want := now.In(c.loc).Zone()
+		_, offset := now.In(&conf.Timezone).Zone()
+		if offset != want {
+			t.Errorf("tz %s: want offset (%v), get (%v)", c.tz, want, offset)
+		}
+	}
+}
+
+func TestPresetConffile(t *testing.T) {
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader("preset combined"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined || c.DateFormat != goaccessfmt.Dates.Apache || c.TimeFormat != goaccessfmt.Times.Fmt24 {
+		t.Error("conf does not match that of combined")
+	}
+
+	c, err = goaccessfmt.ParseConfigReader(strings.NewReader("preset combined\ndate-format %Y-%m-%d\ntime-format %T"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined || c.DateFormat != "%Y-%m-%d" || c.TimeFormat != "%T" {
+		t.Error("date-format and time-format do not override preset")
+	}
+
+	_, err = goaccessfmt.ParseConfigReader(strings.NewReader("preset combind"))
+	if err == nil {
+		t.Error("want error for unknown preset, get nil")
+	}
+}
+
+func TestKeyValueConffile(t *testing.T) {
+	config := `Log-Format = combined
+double-decode = true
+TZ UTC+8`
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.Combined, c.LogFormat)
+	}
+	if !c.DoubleDecodeEnabled {
+		t.Error("double decode is not enabled")
+	}
+	loc := c.Timezone
+	_, offset := time.Now().In(&loc).Zone()
+	if offset != 8*60*60 {
+		t.Errorf("want (%v), get (%v)", 8*60*60, offset)
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goaccess.conf")
+	if err := os.WriteFile(path, []byte("log-format combined\ntz UTC+8\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := goaccessfmt.ParseConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.Combined, c.LogFormat)
+	}
+
+	if err := os.WriteFile(path, []byte("log-format combind\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = goaccessfmt.ParseConfigFile(path)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("want error mentioning %s, get (%v)", path, err)
+	}
+
+	_, err = goaccessfmt.ParseConfigFile(filepath.Join(t.TempDir(), "missing.conf"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want (%v), get (%v)", os.ErrNotExist, err)
+	}
+}
+
+func TestHalfHourTimezoneConffile(t *testing.T) {
+	cases := []struct {
+		tz     string
+		offset int
+	}{
+		{"UTC+05:30", 5*60*60 + 30*60},
+		{"UTC-09:30", -(9*60*60 + 30*60)},
+		{"UTC-00:30", -30 * 60},
+		{"UTC+8", 8 * 60 * 60},
+	}
+	for _, c := range cases {
+		conf, err := goaccessfmt.ParseConfigReader(strings.NewReader("log-format combined\ntz " + c.tz))
+		if err != nil {
+			t.Errorf("tz %s: %v", c.tz, err)
+			continue
+		}
+		loc := conf.Timezone
+		_, offset := time.Now().In(&loc).Zone()
+		if offset != c.offset {
+			t.Errorf("tz %s: want offset (%v), get (%v)", c.tz, c.offset, offset)
+		}
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	configs := []string{
+		"log-format %d\\t%t\\t%h\\t\"%r\"\\t%s\ndate-format %Y-%m-%d\ntime-format %H:%M:%S\ntz UTC+05:30\ndouble-decode true",
+		"preset combined\ntime-format %T\ntz Asia/Shanghai",
+		"log-format cloudfront",
+		"log-format apache_error\ntz local",
+	}
+	for _, config := range configs {
+		c, err := goaccessfmt.ParseConfigReader(strings.NewReader(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var written strings.Builder
+		if err := c.WriteConfig(&written); err != nil {
+			t.Fatal(err)
+		}
+		c2, err := goaccessfmt.ParseConfigReader(strings.NewReader(written.String()))
+		if err != nil {
+			t.Fatalf("%q: %v", written.String(), err)
+		}
+		if c2.LogFormat != c.LogFormat || c2.DateFormat != c.DateFormat || c2.TimeFormat != c.TimeFormat ||
+			c2.DoubleDecodeEnabled != c.DoubleDecodeEnabled || c2.FormatName != c.FormatName {
+			t.Errorf("%q: want (%+v), get (%+v)", written.String(), c, c2)
+		}
+		now := time.Now()
+		_, want := now.In(&c.Timezone).Zone()
+		_, offset := now.In(&c2.Timezone).Zone()
+		if offset != want {
+			t.Errorf("%q: want offset (%v), get (%v)", written.String(), want, offset)
+		}
+
+		var rewritten strings.Builder
+		if err := c2.WriteConfig(&rewritten); err != nil {
+			t.Fatal(err)
+		}
+		if rewritten.String() != written.String() {
+			t.Errorf("want (%q), get (%q)", written.String(), rewritten.String())
+		}
+	}
+}
+
+func TestCommentConffile(t *testing.T) {
+	config := `# goaccess.conf
+
+#log-format vcombined
+log-format combined
+	# tz UTC+8
+#double-decode true
+
+`
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.Combined, c.LogFormat)
+	}
+	if c.DoubleDecodeEnabled {
+		t.Error("double decode is enabled by a comment")
+	}
+	if name := c.Timezone.String(); name != "UTC" {
+		t.Errorf("want (UTC), get (%v)", name)
+	}
+}
+
+func TestLogFormatPrecedenceConffile(t *testing.T) {
+	// a preset name ignores date-format and time-format
+	c, err := goaccessfmt.ParseConfigReader(strings.NewReader("log-format combined\ndate-format %Y-%m-%d\ntime-format %T"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != goaccessfmt.Logs.Combined || c.DateFormat != goaccessfmt.Dates.Apache || c.TimeFormat != goaccessfmt.Times.Fmt24 {
+		t.Error("date-format and time-format override the log-format preset")
+	}
+
+	// an inline format requires both
+	c, err = goaccessfmt.ParseConfigReader(strings.NewReader("log-format %h\ndate-format %Y-%m-%d\ntime-format %T"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogFormat != "%h" || c.DateFormat != "%Y-%m-%d" || c.TimeFormat != "%T" {
+		t.Errorf("want inline format, get (%v, %v, %v)", c.LogFormat, c.DateFormat, c.TimeFormat)
+	}
+	for _, config := range []string{
+		"log-format %h\ndate-format %Y-%m-%d",
+		"log-format %h\ntime-format %T",
+	} {
+		if _, err := goaccessfmt.ParseConfigReader(strings.NewReader(config)); err == nil {
+			t.Errorf("%q: want error for a missing date-format or time-format", config)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package goaccessfmt
+
+import (
+	"errors"
+	"time"
+)
+
+// DetectFormat returns the name of the preset that parses the most of the
+// sample lines. Ties are broken in favor of the preset filling more fields
+// (e.g. vcombined over combined), then the earlier preset.
+func DetectFormat(lines []string) (string, error) {
+	best := ""
+	bestMatches, bestFields := 0, 0
+	for _, preset := range presets {
+		conf, err := SetupConfig(preset.logfmt, preset.datefmt, preset.timefmt, time.UTC)
+		if err != nil {
+			return "", err
+		}
+		conf.ApacheErrorLog = preset.isApacheError()
+		matches, fields := 0, 0
+		for _, line := range lines {
+			if logitem, err := ParseLine(conf, line); err == nil {
+				matches++
+				fields += countFields(logitem)
+			}
+		}
+		if matches > bestMatches || (matches == bestMatches && matches > 0 && fields > bestFields) {
+			best, bestMatches, bestFields = preset.name, matches, fields
+		}
+	}
+	if best == "" {
+		return "", errors.New("no preset matches the sample lines")
+	}
+	return best, nil
+}
+
+// countFields returns the number of non-empty string fields of logitem.
+func countFields(logitem *GLogItem) int {
+	n := 0
+	for _, field := range []string{
+		logitem.Agent, logitem.Host, logitem.Method, logitem.Protocol,
+		logitem.Qstr, logitem.Ref, logitem.Req, logitem.StatusReason,
+		logitem.VHost, logitem.Userid, logitem.CacheStatus, logitem.MimeType,
+		logitem.TLSType, logitem.TLSCypher, logitem.Server, logitem.RequestID,
+		logitem.TraceID, logitem.GeoLocation,
+	} {
+		if field != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,42 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{
+			`114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "curl/8.0.1"`,
+			`114.5.1.5 - - [11/Jun/2023:11:23:46 +0800] "GET / HTTP/1.1" 200 1024 "https://example.com/" "Mozilla/5.0"`,
+		}, "combined"},
+		{[]string{
+			`example.com:443 114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568 "-" "curl/8.0.1"`,
+		}, "vcombined"},
+		{[]string{
+			`114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568`,
+		}, "common"},
+		{[]string{
+			`{"level":"info","ts":1646861401.5241024,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"127.0.0.1","remote_port":"41342","client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/","headers":{"User-Agent":["curl/7.82.0"]}},"duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Content-Type":["text/html; charset=utf-8"]}}`,
+		}, "caddy"},
+	}
+	for _, c := range cases {
+		name, err := goaccessfmt.DetectFormat(c.lines)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if name != c.want {
+			t.Errorf("want (%v), get (%v)", c.want, name)
+		}
+	}
+
+	if _, err := goaccessfmt.DetectFormat([]string{"garbage"}); err == nil {
+		t.Error("want error, get nil")
+	}
+}
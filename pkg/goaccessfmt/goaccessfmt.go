@@ -6,53 +6,78 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/itchyny/timefmt-go"
 )
 
 // GPreConfLog represents predefined log formats
 type GPreConfLog struct {
-	Combined     string
-	VCombined    string
-	Common       string
-	VCommon      string
-	W3C          string
-	CloudFront   string
-	CloudStorage string
-	AWSELB       string
-	Squid        string
-	AWSS3        string
-	Caddy        string
-	AWSALB       string
-	TraefikCLF   string
+	Combined           string
+	VCombined          string
+	Common             string
+	VCommon            string
+	W3C                string
+	CloudFront         string
+	CloudStorage       string
+	AWSELB             string
+	Squid              string
+	AWSS3              string
+	Caddy              string
+	AWSALB             string
+	TraefikCLF         string
+	CaddyContentLength string
+	NginxUpstream      string
+	CaddyExtended      string
+	Envoy              string
+	Varnish            string
+	AWSALBFull         string
+	TraefikJSON        string
 }
 
 var Logs = GPreConfLog{
-	Combined:     `%h %^[%d:%t %^] "%r" %s %b "%R" "%u"`,
-	VCombined:    `%v:%^ %h %^[%d:%t %^] "%r" %s %b "%R" "%u"`,
-	Common:       `%h %^[%d:%t %^] "%r" %s %b`,
-	VCommon:      `%v:%^ %h %^[%d:%t %^] "%r" %s %b`,
-	W3C:          `%d %t %^ %m %U %q %^ %^ %h %u %R %s %^ %^ %L`,
-	CloudFront:   `%d\t%t\t%^\t%b\t%h\t%m\t%v\t%U\t%s\t%R\t%u\t%q\t%^\t%C\t%^\t%^\t%^\t%^\t%T\t%^\t%K\t%k\t%^\t%H\t%^`,
-	CloudStorage: `"%x","%h",%^,%^,"%m","%U","%s",%^,"%b","%D",%^,"%R","%u"`,
-	AWSELB:       `%^ %dT%t.%^ %^ %h:%^ %^ %^ %T %^ %s %^ %^ %b "%r" "%u" %k %K %^ "%^" "%v"`,
-	Squid:        `%^ %^ %^ %v %^: %x.%^ %~%L %h %^/%s %b %m %U`,
-	AWSS3:        `%^ %v [%d:%t %^] %h %^"%r" %s %^ %b %^ %L %^ "%R" "%u"`,
-	Caddy:        `{ "ts": "%x.%^", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U", "headers": {"User-Agent": ["%u"], "Referer": ["%R"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "size": "%b","status": "%s", "resp_headers": { "Content-Type": ["%M"] } }`,
-	AWSALB:       `%^ %dT%t.%^ %v %h:%^ %^ %^ %T %^ %s %^ %^ %b "%r" "%u" %k %K %^`,
-	TraefikCLF:   `%h - %e [%d:%t %^] "%r" %s %b "%R" "%u" %^ "%v" "%U" %Lms`,
+	Combined:           `%h %^[%d:%t %^] "%r" %s %b "%R" "%u"`,
+	VCombined:          `%v:%^ %h %^[%d:%t %^] "%r" %s %b "%R" "%u"`,
+	Common:             `%h %^[%d:%t %^] "%r" %s %b`,
+	VCommon:            `%v:%^ %h %^[%d:%t %^] "%r" %s %b`,
+	W3C:                `%d %t %^ %m %U %q %^ %^ %h %u %R %s %^ %^ %L`,
+	CloudFront:         `%d\t%t\t%^\t%b\t%h\t%m\t%v\t%U\t%s\t%R\t%u\t%q\t%^\t%C\t%^\t%^\t%^\t%^\t%T\t%^\t%K\t%k\t%^\t%H\t%^`,
+	CloudStorage:       `"%x","%h",%^,%^,"%m","%U","%s",%^,"%b","%D",%^,"%R","%u"`,
+	AWSELB:             `%^ %dT%t.%^ %^ %h:%^ %^ %^ %T %^ %s %^ %^ %b "%r" "%u" %k %K %^ "%^" "%v"`,
+	Squid:              `%^ %^ %^ %v %^: %x.%^ %~%L %h %^/%s %b %m %U %^`,
+	AWSS3:              `%^ %v [%d:%t %^] %h %^"%r" %s %^ %b %^ %L %^ "%R" "%u"`,
+	Caddy:              `{ "ts": "%x", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U", "headers": {"User-Agent": ["%u"], "Referer": ["%R"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "size": "%b","status": "%s", "resp_headers": { "Content-Type": ["%M"] } }`,
+	AWSALB:             `%^ %dT%t.%^ %v %h:%^ %^ %^ %T %^ %s %^ %^ %b "%r" "%u" %k %K %^`,
+	TraefikCLF:         `%h - %e [%d:%t %^] "%r" %s %b "%R" "%u" %^ "%v" "%U" %Lms`,
+	CaddyContentLength: `{ "ts": "%x", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U", "headers": {"User-Agent": ["%u"], "Referer": ["%R"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "status": "%s", "resp_headers": { "Content-Type": ["%M"], "Content-Length": ["%b"] } }`,
+	NginxUpstream:      `%h %^[%d:%t %^] "%r" %s %b "%R" "%u" %T %^`,
+	CaddyExtended:      `{ "ts": "%x", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U?%q", "headers": {"User-Agent": ["%u"], "Referer": ["%R"], "X-Request-Id": ["%i"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "size": "%b","status": "%s", "resp_headers": { "Content-Type": ["%M"] } }`,
+	Envoy:              `{ "start_time": "%x", "method": "%m", "path": "%U", "protocol": "%H", "response_code": "%s", "bytes_sent": "%b", "duration": "%L", "user_agent": "%u", "request_id": "%i", "authority": "%v", "downstream_remote_address": "%h" }`,
+	Varnish:            `%h %^[%d:%t %^] "%r" %s %b "%R" "%u" %C`,
+	AWSALBFull:         `%^ %dT%t.%^ %^ %h:%^ %^ %^ %T %^ %s %^ %^ %b "%r" "%u" %k %K %^ "%I" "%v" %^`,
+	TraefikJSON:        `{ "ClientHost": "%h", "StartUTC": "%x", "RequestMethod": "%m", "RequestHost": "%v", "RequestPath": "%U?%q", "RequestProtocol": "%H", "DownstreamStatus": "%s", "DownstreamContentSize": "%b", "Duration": "%n", "request_User-Agent": "%u", "request_Referer": "%R" }`,
 }
 
+// CloudFrontCacheStatuses are the CloudFront x-edge-result-type values
+// not known by goaccess as cache statuses. %C always accepts them, so that
+// the CloudFront preset keeps them however it is set up.
+var CloudFrontCacheStatuses = []string{"RefreshHit", "Error", "Redirect", "LimitExceeded", "CapacityExceeded"}
+
 // GPreConfTime represents predefined log time formats
 type GPreConfTime struct {
-	Fmt24 string
-	Usec  string
-	Sec   string
+	Fmt24   string
+	Usec    string
+	Sec     string
+	ISO8601 string
 }
 
 // GPreConfDate represents predefined log date formats
@@ -64,9 +89,10 @@ type GPreConfDate struct {
 }
 
 var Times = GPreConfTime{
-	Fmt24: "%H:%M:%S",
-	Usec:  "%f", // Cloud Storage (usec)
-	Sec:   "%s", // Squid (sec)
+	Fmt24:   "%H:%M:%S",
+	Usec:    "%f",                     // Cloud Storage (usec)
+	Sec:     "%s",                     // Squid (sec)
+	ISO8601: "%Y-%m-%dT%H:%M:%S.%f%z", // Envoy, Traefik JSON (date and time, for %x)
 }
 
 var Dates = GPreConfDate{
@@ -109,13 +135,27 @@ var httpMethods = []string{
 	"ORDERPATCH",
 }
 
-func extractMethod(token []byte) []byte {
+// extractMethod returns the longest known method the token starts with,
+// so that an extra method (e.g. "GETALL") is not shadowed by a built-in
+// one it starts with.
+func extractMethod(conf Config, token []byte) []byte {
+	upper := string(bytes.ToUpper(token))
+	longest := ""
 	for _, method := range httpMethods {
-		if strings.HasPrefix(string(bytes.ToUpper(token)), method) {
-			return []byte(method)
+		if len(method) > len(longest) && strings.HasPrefix(upper, method) {
+			longest = method
 		}
 	}
-	return nil
+	for _, method := range conf.ExtraMethods {
+		method = strings.ToUpper(method)
+		if len(method) > len(longest) && strings.HasPrefix(upper, method) {
+			longest = method
+		}
+	}
+	if longest == "" {
+		return nil
+	}
+	return []byte(longest)
 }
 
 var httpProtocols = []string{
@@ -125,9 +165,27 @@ var httpProtocols = []string{
 	"HTTP/3",
 }
 
-func extractProtocol(token []byte) []byte {
+// protocolAliases are protocol names (ALPN IDs) logged by some proxies
+// instead of the HTTP version.
+var protocolAliases = map[string]string{
+	"H2":  "HTTP/2",
+	"H2C": "HTTP/2",
+	"H3":  "HTTP/3",
+}
+
+func extractProtocol(conf Config, token []byte) []byte {
+	upper := string(bytes.ToUpper(token))
 	for _, protocol := range httpProtocols {
-		if strings.HasPrefix(string(bytes.ToUpper(token)), protocol) {
+		if strings.HasPrefix(upper, protocol) {
+			return []byte(protocol)
+		}
+	}
+	if protocol, ok := protocolAliases[upper]; ok {
+		return []byte(protocol)
+	}
+	for _, protocol := range conf.ExtraProtocols {
+		protocol = strings.ToUpper(protocol)
+		if protocol != "" && strings.HasPrefix(upper, protocol) {
 			return []byte(protocol)
 		}
 	}
@@ -135,19 +193,34 @@ func extractProtocol(token []byte) []byte {
 }
 
 type GLogItem struct {
-	Agent       string
-	Host        string
-	Method      string
-	Protocol    string
-	Qstr        string
-	Ref         string
-	Req         string
-	Status      int
-	VHost       string
-	Userid      string
-	CacheStatus string
-
-	RespSize  uint64
+	Agent        string
+	Host         string
+	Method       string
+	Protocol     string
+	Qstr         string
+	Ref          string
+	Req          string
+	Status       int
+	StatusReason string
+	VHost        string
+	Userid       string
+	CacheStatus  string
+	// Port is the client port logged along with the host (%h), or 0
+	Port int
+	// UpstreamStatus is the second code of a status pair (%s, e.g. "502 200"
+	// or "502/200"), such as the backend status logged by a proxy, or 0
+	// without one (e.g. "502 -")
+	UpstreamStatus int
+	// CacheStatusRaw is the cache status (%C) as logged when it is not a
+	// known one, which leaves CacheStatus empty
+	CacheStatusRaw string
+
+	RespSize uint64
+	// ReqSize is the size of the request as received (%B), e.g. the bytes
+	// received logged by Apache's %I
+	ReqSize uint64
+	// ServeTime is the time taken to serve the request in microseconds,
+	// whatever the unit of the specifier (%T, %L, %D, %n or %w)
 	ServeTime uint64
 
 	// UMS
@@ -156,28 +229,61 @@ type GLogItem struct {
 	TLSCypher string
 
 	// Extension
-	Server string
+	Server      string
+	RequestID   string
+	TraceID     string
+	GeoLocation string
+	// Level and Message are set for Apache error logs (ApacheErrorLog)
+	Level   string
+	Message string
+	// Timings are the components of a slash-separated timing group (%w),
+	// as logged. Being a slice, it makes GLogItem not comparable with ==,
+	// use Equal instead.
+	Timings []uint64
 
 	Dt time.Time
+	// DtEnd is the end timestamp (%X), e.g. when the response was sent
+	DtEnd time.Time
+
+	// FormatName is copied from Config.FormatName of the config that parsed
+	// this item.
+	FormatName string
+	// RecoveredQuoting is set when a quoted field containing unescaped quotes
+	// was split heuristically (see Config.RecoverQuoting).
+	RecoveredQuoting bool
+	// Malformed is set when the request could not be parsed and has been
+	// kept raw (see Config.RecoverMalformedReq).
+	Malformed bool
+	// RawReq is Req as logged, when Config.NormalizeReq is set.
+	RawReq string
 }
 
+// Equal reports whether a and b carry the same parsed fields.
+// FormatName, RecoveredQuoting, Malformed and RawReq are not compared.
 func (a GLogItem) Equal(b GLogItem) bool {
 	if a.Agent != b.Agent ||
 		a.Host != b.Host ||
+		a.Port != b.Port ||
 		a.Method != b.Method ||
 		a.Protocol != b.Protocol ||
 		a.Qstr != b.Qstr ||
 		a.Ref != b.Ref ||
 		a.Req != b.Req ||
 		a.Status != b.Status ||
+		a.StatusReason != b.StatusReason ||
+		a.UpstreamStatus != b.UpstreamStatus ||
 		a.VHost != b.VHost ||
 		a.Userid != b.Userid ||
 		a.CacheStatus != b.CacheStatus ||
+		a.CacheStatusRaw != b.CacheStatusRaw ||
 		a.RespSize != b.RespSize ||
+		a.ReqSize != b.ReqSize ||
 		a.ServeTime != b.ServeTime ||
 		a.MimeType != b.MimeType ||
 		a.TLSType != b.TLSType ||
-		a.TLSCypher != b.TLSCypher || a.Server != b.Server || !a.Dt.Equal(b.Dt) {
+		a.TLSCypher != b.TLSCypher || a.Server != b.Server || a.RequestID != b.RequestID || a.TraceID != b.TraceID ||
+		a.GeoLocation != b.GeoLocation || a.Level != b.Level || a.Message != b.Message ||
+		!slices.Equal(a.Timings, b.Timings) || !a.Dt.Equal(b.Dt) || !a.DtEnd.Equal(b.DtEnd) {
 		return false
 	}
 	return true
@@ -190,28 +296,68 @@ const (
 	ERR_SPEC_TOKN_INV
 	ERR_SPEC_SFMT_MIS
 	ERR_SPEC_LINE_INV
+	ERR_SPEC_TOKN_LEN
+	ERR_SPEC_TOKN_SWP
 )
 
-func parseSpecErr(code errSpec, spec byte, tkn []byte) error {
+// SpecError is the error returned when a specifier fails to parse its token.
+type SpecError struct {
+	Code errSpec
+	// Spec is the specifier character, e.g. 's' for "%s"
+	Spec  byte
+	Token string
+}
+
+func (e *SpecError) Error() string {
 	tknStr := "-"
-	if len(tkn) > 0 {
-		tknStr = string(tkn)
+	if e.Token != "" {
+		tknStr = e.Token
 	}
 
-	switch code {
+	switch e.Code {
 	case ERR_SPEC_TOKN_NUL:
-		return fmt.Errorf("token for '%%%c' specifier is NULL", spec)
+		return fmt.Sprintf("token for '%%%c' specifier is NULL", e.Spec)
 	case ERR_SPEC_TOKN_INV:
-		return fmt.Errorf("token '%s' doesn't match specifier '%%%c'", tknStr, spec)
+		return fmt.Sprintf("token '%s' doesn't match specifier '%%%c'", tknStr, e.Spec)
 	case ERR_SPEC_SFMT_MIS:
-		return fmt.Errorf("missing braces '%s' and ignore chars for specifier '%%%c'", tknStr, spec)
+		return fmt.Sprintf("missing braces '%s' and ignore chars for specifier '%%%c'", tknStr, e.Spec)
 	case ERR_SPEC_LINE_INV:
-		return errors.New("incompatible format due to early parsed line ending '\\0'")
+		return "incompatible format due to early parsed line ending '\\0'"
+	case ERR_SPEC_TOKN_LEN:
+		return fmt.Sprintf("token '%s' is shorter than the date format for specifier '%%%c'", tknStr, e.Spec)
+	case ERR_SPEC_TOKN_SWP:
+		if e.Spec == 'd' {
+			return fmt.Sprintf("token '%s' looks like a time, not a date, for specifier '%%%c' (are %%d and %%t swapped?)", tknStr, e.Spec)
+		}
+		return fmt.Sprintf("token '%s' looks like a date, not a time, for specifier '%%%c' (are %%d and %%t swapped?)", tknStr, e.Spec)
 	default:
-		return fmt.Errorf("unknown error code: %d", code)
+		return fmt.Sprintf("unknown error code: %d", e.Code)
 	}
 }
 
+// ErrJSONDecode is wrapped by the error returned for a line which is not
+// valid JSON, when the log format is JSON.
+var ErrJSONDecode = errors.New("malformed JSON line")
+
+// ParseError is the error returned when the value of a JSON key fails to
+// parse with its format. Err is usually a *SpecError.
+type ParseError struct {
+	Key string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("JSON key '%s': %v", e.Key, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseSpecErr(code errSpec, spec byte, tkn []byte) error {
+	return &SpecError{Code: code, Spec: spec, Token: string(tkn)}
+}
+
 // isJSONLogFormat determines if we have a valid JSON format
 func isJSONLogFormat(fmt string) bool {
 	decoder := json.NewDecoder(strings.NewReader(fmt))
@@ -266,28 +412,190 @@ func unescapeStr(src string) string {
 	return dest.String()
 }
 
+// DecodeMode is a way to decode URL-encoded fields (%U, %q, %r and %u).
+type DecodeMode int
+
+const (
+	// QueryURLDecode decodes "%XX" escapes and '+' as a space, as in a
+	// query string (url.QueryUnescape).
+	QueryURLDecode DecodeMode = iota
+	// RawURLDecode decodes "%XX" escapes only, keeping '+'
+	// (url.PathUnescape), e.g. for raw bytes logged as the request.
+	RawURLDecode
+)
+
 type Config struct {
 	LogFormat           string
 	DateFormat          string
 	TimeFormat          string
 	Timezone            time.Location
 	DoubleDecodeEnabled bool
+	// DisableURLDecode keeps URL-encoded fields (%U, %q, %r and %u) as
+	// logged, only trimmed, e.g. "%2F" stays as is. This is for logs with
+	// URIs already decoded or deliberately raw, and skips the decoding work.
+	DisableURLDecode bool
+	// UseLogTimezone makes the offset parsed by %z, in the log format (e.g.
+	// "+0800" in "[11/Jun/2023:11:23:45 +0800]") or in the time format of
+	// %t, the timezone of Dt instead of Timezone. Otherwise the offset is
+	// ignored, as in goaccess. Timestamps parsed by %x always keep theirs.
+	UseLogTimezone bool
+	// DecodeMode selects how URL-encoded fields are decoded.
+	DecodeMode DecodeMode
+	// LenientParsing makes parsing continue past specifiers that fail, and
+	// ParseLine return the partially populated item along with the first
+	// error.
+	LenientParsing bool
+	// Strict makes a line fail to parse when a size (%b or %B) is neither a
+	// number nor "-", or when data is left after the last field of the
+	// format, including in a JSON value, instead of ignoring it.
+	Strict bool
+	// OptionalDateBrackets makes %d, %t and %x accept timestamps with or
+	// without surrounding '[' and ']'.
+	OptionalDateBrackets bool
+	// IntServeTimeNanos makes %T treat integer tokens as nanoseconds instead
+	// of seconds, as logged by Caddy with some encoders. Tokens with a
+	// decimal point are still seconds.
+	IntServeTimeNanos bool
+	// DefaultStatus, if non-zero, is set as the status of every item when
+	// the format has no %s specifier.
+	DefaultStatus int
+	// RecoverQuoting enables a heuristic for quoted fields containing
+	// unescaped quotes: the field is closed by the quote followed by what
+	// the format expects next, instead of the first quote.
+	RecoverQuoting bool
+	// RecoverMalformedReq keeps the raw request in Req when %r or %U cannot
+	// be parsed, taking the rest of the line if the field is not delimited,
+	// and flags the item as Malformed instead of failing.
+	RecoverMalformedReq bool
+	// JSONEscapedFields reverses JSON string escaping (e.g. "\"", "\/",
+	// "\u00e9") and nginx "\xHH" escaping in %R and %u, for text formats
+	// logging these fields escaped.
+	JSONEscapedFields bool
+	// SplitHostList makes %h take the first IP address when the host is a
+	// comma-separated list, without using the "~h{, }" syntax.
+	SplitHostList bool
+	// HostFallbackKey is a JSON key (e.g. "request.headers.X-Real-Ip[0]")
+	// whose value replaces the host when it is empty or in TrustedProxies.
+	HostFallbackKey string
+	TrustedProxies  []netip.Prefix
+	// ExtraMethods are accepted by %m and %r in addition to the HTTP and
+	// WebDAV methods known by goaccess, case-insensitively.
+	ExtraMethods []string
+	// ExtraProtocols are accepted by %H and %r in addition to the HTTP
+	// versions known by goaccess, case-insensitively. "h2" and "h3" are
+	// always accepted as HTTP/2 and HTTP/3.
+	ExtraProtocols []string
+	// CacheStatuses are accepted by %C in addition to the cache statuses
+	// known by goaccess (HIT, MISS, etc.) and CloudFrontCacheStatuses,
+	// case-insensitively.
+	CacheStatuses []string
+	// TokenTransform, if not nil, rewrites the token of a string field (%h,
+	// %v, %e, %C, %U, %q, %r, %R, %u, %k, %K, %M, %S, %i, %I and %g) before it is
+	// stored, e.g. to strip a prefix. It runs after URL-decoding (%U, %q,
+	// and the request of %r) and host port stripping.
+	TokenTransform func(spec byte, raw []byte) []byte
+	// ZoneOffsets maps zone abbreviations (e.g. "PST") parsed by %Z in the
+	// time format to offsets in seconds east of UTC. Abbreviations not in
+	// the map are ignored, leaving the time in Timezone.
+	ZoneOffsets map[string]int
+	// NormalizeReq, if any option is set, stores Req normalized (see
+	// GLogItem.NormalizedReq) and keeps the original in RawReq.
+	NormalizeReq ReqNormalization
+	// SkipIncompleteLastLine makes the streaming helpers (ParseReader, etc.)
+	// drop the last line of the input if it has no trailing newline, e.g.
+	// when reading a log file while it is being written.
+	SkipIncompleteLastLine bool
+	// FormatName names this config, e.g. the preset it comes from. It is
+	// copied to every parsed GLogItem.
+	FormatName string
+	// ApacheErrorLog makes lines be parsed as Apache error logs with a
+	// dedicated parser instead of LogFormat, DateFormat and TimeFormat. The
+	// apache_error preset has no formats and needs it set.
+	ApacheErrorLog bool
+
+	bandwidth  bool
+	status     bool
+	dateMinLen int
+	timeOffset bool
+	timeZone   bool
+	isJSON     bool
+	jsonMap    map[string]string
+	compiled   map[string][]fmtOp
+	isCEF      bool
+	cefHeader  []string
+	cefMap     map[string]string
+	warnings   []string
+	fallbacks  []Config
+}
+
+// transform applies TokenTransform, if any, to the token of spec.
+func (conf Config) transform(spec byte, tkn []byte) []byte {
+	if conf.TokenTransform == nil {
+		return tkn
+	}
+	return conf.TokenTransform(spec, tkn)
+}
+
+// JSONFieldMap returns a copy of the mapping from flattened JSON keys (e.g.
+// "request.client_ip") to their format specifiers, or nil for non-JSON formats.
+func (conf Config) JSONFieldMap() map[string]string {
+	return maps.Clone(conf.jsonMap)
+}
+
+// Validate checks that every key of a JSON format appears in sampleLine, and
+// reports the missing ones, which would otherwise be silently left empty
+// (e.g. "clientip" in the format but "client_ip" in the log). It does nothing
+// for non-JSON formats.
+func (conf Config) Validate(sampleLine string) error {
+	if !conf.isJSON {
+		return nil
+	}
+	seen := make(map[string]bool)
+	err := parseJSONString(sampleLine, func(key, value string) error {
+		seen[key] = true
+		seen[trimArrayIndex(key)] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrJSONDecode, err)
+	}
+	var missing []string
+	for key := range conf.jsonMap {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("JSON keys missing from sample line: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
 
-	bandwidth bool
-	isJSON    bool
-	jsonMap   map[string]string
+// Warnings returns the non-fatal problems found in the format by SetupConfig.
+func (conf Config) Warnings() []string {
+	return conf.warnings
 }
 
+// containsSpecifier sets the flags of the specifiers found in the compiled
+// formats, so that fixed widths (e.g. "%{3}s") and JSON or CEF values count.
 func containsSpecifier(conf *Config) {
 	// Reset flags
 	conf.bandwidth = false
+	conf.status = false
 
-	if conf.LogFormat == "" {
-		return
-	}
-
-	if strings.Contains(conf.LogFormat, "%b") {
-		conf.bandwidth = true
+	for _, ops := range conf.compiled {
+		for _, op := range ops {
+			if op.kind != fmtOpSpecifier {
+				continue
+			}
+			switch op.spec[0] {
+			case 'b':
+				conf.bandwidth = true
+			case 's':
+				conf.status = true
+			}
+		}
 	}
 }
 
@@ -297,10 +605,15 @@ type callback func(key, value string) error
 // parseJSONString parses a JSON string and calls the callback function for each key-value pair
 func parseJSONString(jsonStr string, callback callback) error {
 	var data interface{}
-	err := json.Unmarshal([]byte(jsonStr), &data)
-	if err != nil {
+	// keep numbers as written, float64 loses digits of long timestamps
+	decoder := json.NewDecoder(strings.NewReader(jsonStr))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
 		return err
 	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
 
 	return parseValue("", data, callback)
 }
@@ -323,6 +636,8 @@ func parseValue(prefix string, v interface{}, callback callback) error {
 		}
 	case string:
 		return callback(prefix, value)
+	case json.Number:
+		return callback(prefix, expandExponent(string(value)))
 	case float64:
 		return callback(prefix, strconv.FormatFloat(value, 'f', -1, 64))
 	case bool:
@@ -335,6 +650,45 @@ func parseValue(prefix string, v interface{}, callback callback) error {
 	return nil
 }
 
+// expandExponent rewrites a JSON number in exponent form, such as
+// "1.6785513e9", as a plain decimal ("1678551300") without going through
+// float64. Other numbers are returned as is.
+func expandExponent(num string) string {
+	i := strings.IndexAny(num, "eE")
+	if i == -1 {
+		return num
+	}
+	exp, err := strconv.Atoi(num[i+1:])
+	if err != nil {
+		return num
+	}
+	mantissa, sign := num[:i], ""
+	if strings.HasPrefix(mantissa, "-") {
+		mantissa, sign = mantissa[1:], "-"
+	}
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := intPart + fracPart
+	point := len(intPart) + exp
+	var intDigits, fracDigits string
+	switch {
+	case point <= 0:
+		intDigits, fracDigits = "0", strings.Repeat("0", -point)+digits
+	case point >= len(digits):
+		intDigits = digits + strings.Repeat("0", point-len(digits))
+	default:
+		intDigits, fracDigits = digits[:point], digits[point:]
+	}
+	intDigits = strings.TrimLeft(intDigits, "0")
+	if intDigits == "" {
+		intDigits = "0"
+	}
+	fracDigits = strings.TrimRight(fracDigits, "0")
+	if fracDigits == "" {
+		return sign + intDigits
+	}
+	return sign + intDigits + "." + fracDigits
+}
+
 func joinKey(prefix, key string) string {
 	if prefix == "" {
 		return key
@@ -348,8 +702,12 @@ func SetupConfig(logfmt string, datefmt string, timefmt string, timezone *time.L
 	conf.LogFormat = unescapeStr(logfmt)
 	conf.DateFormat = unescapeStr(datefmt)
 	conf.TimeFormat = unescapeStr(timefmt)
+	// time.Local is initialized lazily, make sure it is loaded before copying
+	_ = timezone.String()
 	conf.Timezone = *timezone
-	containsSpecifier(&conf)
+	conf.dateMinLen = minTimeLen(conf.DateFormat)
+	conf.timeOffset = strings.Contains(conf.TimeFormat, "%z")
+	conf.timeZone = strings.Contains(conf.TimeFormat, "%Z")
 
 	if conf.isJSON {
 		conf.jsonMap = make(map[string]string)
@@ -360,81 +718,189 @@ func SetupConfig(logfmt string, datefmt string, timefmt string, timezone *time.L
 		if err != nil {
 			return Config{}, err
 		}
+		for _, spec := range conf.jsonMap {
+			if err := checkAdjacentSpecifiers(spec); err != nil {
+				return Config{}, err
+			}
+		}
+	} else if isCEFLogFormat(conf.LogFormat) {
+		conf.isCEF = true
+		if err := setupCEF(&conf); err != nil {
+			return Config{}, err
+		}
+	} else if err := checkAdjacentSpecifiers(conf.LogFormat); err != nil {
+		return Config{}, err
+	}
+
+	if err := compileFormats(&conf); err != nil {
+		return Config{}, err
+	}
+	containsSpecifier(&conf)
+
+	for _, spec := range duplicateSpecifiers(conf.LogFormat) {
+		conf.warnings = append(conf.warnings,
+			fmt.Sprintf("specifier '%%%c' appears more than once, only its first value is kept", spec))
+	}
+	for _, spec := range unknownSpecifiers(conf.compiled) {
+		conf.warnings = append(conf.warnings,
+			fmt.Sprintf("unknown specifier '%%%c' is skipped", spec))
 	}
 
 	return conf, nil
 }
 
+// duplicateSpecifiers finds value-capturing specifiers which appear more
+// than once in the format. Later occurrences are skipped when parsing.
+func duplicateSpecifiers(format string) []byte {
+	var seen, dups []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		j := i + 1
+		if j < len(format) && format[j] == '{' {
+			end := strings.IndexByte(format[j:], '}')
+			if end == -1 {
+				break
+			}
+			j += end + 1
+		}
+		if j >= len(format) {
+			break
+		}
+		spec := format[j]
+		i = j
+		if spec == '^' || spec == '~' {
+			continue
+		}
+		if bytes.IndexByte(seen, spec) == -1 {
+			seen = append(seen, spec)
+		} else if bytes.IndexByte(dups, spec) == -1 {
+			dups = append(dups, spec)
+		}
+	}
+	return dups
+}
+
+// knownSpecifiers are the specifiers handled by parseSpecifier.
+const knownSpecifiers = "dtxXvezChmUqHrsbBRuLTDnkKM~SiIgw^"
+
+// unknownSpecifiers finds the specifiers of the compiled formats which
+// parseSpecifier does not handle, in byte order.
+func unknownSpecifiers(compiled map[string][]fmtOp) []byte {
+	var unknown []byte
+	for _, ops := range compiled {
+		for _, op := range ops {
+			if op.kind != fmtOpSpecifier {
+				continue
+			}
+			spec := op.spec[0]
+			if strings.IndexByte(knownSpecifiers, spec) == -1 && bytes.IndexByte(unknown, spec) == -1 {
+				unknown = append(unknown, spec)
+			}
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// checkAdjacentSpecifiers rejects formats where a specifier is immediately
+// followed by another one (e.g. "%s%b"), as the first specifier would have
+// no delimiter to stop at. A fixed width (e.g. "%{3}s%b") and "%d%t" are
+// accepted.
+func checkAdjacentSpecifiers(format string) error {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		j := i + 1
+		width := false
+		if j < len(format) && format[j] == '{' {
+			end := strings.IndexByte(format[j:], '}')
+			if end == -1 {
+				return nil
+			}
+			j += end + 1
+			width = true
+		}
+		if j+1 >= len(format) {
+			return nil
+		}
+		spec := format[j]
+		dateTime := isDateTimeSpec([]byte(format[j:]))
+		if spec != '~' && !dateTime && !width && format[j+1] == '%' {
+			return fmt.Errorf("specifier '%%%c' is immediately followed by another specifier, add a delimiter or a fixed width like '%%{N}%c'", spec, spec)
+		}
+		i = j
+	}
+	return nil
+}
+
+// presetFormat is a preset of GetFmtFromPreset.
+type presetFormat struct {
+	name, logfmt, datefmt, timefmt string
+}
+
+// isApacheError reports whether p is parsed with Config.ApacheErrorLog.
+func (p presetFormat) isApacheError() bool {
+	return p.name == apacheErrorPreset
+}
+
+// presets are the formats of GetFmtFromPreset by name, in the order of
+// GPreConfLog.
+var presets = []presetFormat{
+	{"combined", Logs.Combined, Dates.Apache, Times.Fmt24},
+	{"vcombined", Logs.VCombined, Dates.Apache, Times.Fmt24},
+	{"common", Logs.Common, Dates.Apache, Times.Fmt24},
+	{"vcommon", Logs.VCommon, Dates.Apache, Times.Fmt24},
+	{"w3c", Logs.W3C, Dates.W3C, Times.Fmt24},
+	{"cloudfront", Logs.CloudFront, Dates.W3C, Times.Fmt24},
+	{"cloudstorage", Logs.CloudStorage, Dates.Usec, Times.Usec},
+	{"awselb", Logs.AWSELB, Dates.W3C, Times.Fmt24},
+	{"squid", Logs.Squid, Dates.Sec, Times.Sec},
+	{"awss3", Logs.AWSS3, Dates.Apache, Times.Fmt24},
+	{"caddy", Logs.Caddy, Dates.Sec, Times.Sec},
+	{"awsalb", Logs.AWSALB, Dates.W3C, Times.Fmt24},
+	{"traefikclf", Logs.TraefikCLF, Dates.Apache, Times.Fmt24},
+	{"caddy_content_length", Logs.CaddyContentLength, Dates.Sec, Times.Sec},
+	{"nginx_upstream", Logs.NginxUpstream, Dates.Apache, Times.Fmt24},
+	{"caddy_extended", Logs.CaddyExtended, Dates.Sec, Times.Sec},
+	// no formats, see Config.ApacheErrorLog
+	{apacheErrorPreset, "", "", ""},
+	{"envoy", Logs.Envoy, Dates.W3C, Times.ISO8601},
+	{"varnish", Logs.Varnish, Dates.Apache, Times.Fmt24},
+	{"awsalb_full", Logs.AWSALBFull, Dates.W3C, Times.Fmt24},
+	{"traefik_json", Logs.TraefikJSON, Dates.W3C, Times.ISO8601},
+}
+
+// PresetNames returns the names accepted by GetFmtFromPreset, in a stable
+// order.
+func PresetNames() []string {
+	names := make([]string, len(presets))
+	for i, preset := range presets {
+		names[i] = preset.name
+	}
+	return names
+}
+
+// lookupPreset returns the preset named name, case-insensitively.
+func lookupPreset(name string) (presetFormat, bool) {
+	for _, p := range presets {
+		if strings.EqualFold(p.name, name) {
+			return p, true
+		}
+	}
+	return presetFormat{}, false
+}
+
+// GetFmtFromPreset returns the log, date and time formats of a preset. The
+// apache_error preset has none, set Config.ApacheErrorLog instead.
 func GetFmtFromPreset(preset string) (string, string, string, error) {
-	preset = strings.ToUpper(preset)
-	var logfmt string
-	var datefmt string
-	var timefmt string
-	switch preset {
-	case "CLOUDSTORAGE":
-		datefmt = Dates.Usec
-		timefmt = Times.Usec
-	case "SQUID":
-		fallthrough
-	case "CADDY":
-		datefmt = Dates.Sec
-		timefmt = Times.Sec
-	case "AWSELB":
-		fallthrough
-	case "AWSALB":
-		fallthrough
-	case "CLOUDFRONT":
-		fallthrough
-	case "W3C":
-		datefmt = Dates.W3C
-		timefmt = Times.Fmt24
-	case "COMMON":
-		fallthrough
-	case "VCOMMON":
-		fallthrough
-	case "COMBINED":
-		fallthrough
-	case "VCOMBINED":
-		fallthrough
-	case "AWSS3":
-		fallthrough
-	case "TRAEFIKCLF":
-		datefmt = Dates.Apache
-		timefmt = Times.Fmt24
-	default:
+	p, ok := lookupPreset(preset)
+	if !ok {
 		return "", "", "", errors.New("match failed")
 	}
-	switch preset {
-	case "CLOUDSTORAGE":
-		logfmt = Logs.CloudFront
-	case "SQUID":
-		logfmt = Logs.Squid
-	case "CADDY":
-		logfmt = Logs.Caddy
-	case "AWSELB":
-		logfmt = Logs.AWSELB
-	case "AWSALB":
-		logfmt = Logs.AWSALB
-	case "CLOUDFRONT":
-		logfmt = Logs.CloudFront
-	case "W3C":
-		logfmt = Logs.W3C
-	case "COMMON":
-		logfmt = Logs.Common
-	case "VCOMMON":
-		logfmt = Logs.VCommon
-	case "COMBINED":
-		logfmt = Logs.Combined
-	case "VCOMBINED":
-		logfmt = Logs.VCombined
-	case "AWSS3":
-		logfmt = Logs.AWSS3
-	case "TRAEFIKCLF":
-		logfmt = Logs.TraefikCLF
-	default:
-		panic("unreachable")
-	}
-	return logfmt, datefmt, timefmt, nil
+	return p.logfmt, p.datefmt, p.timefmt, nil
 }
 
 // validLine determines if the log string is valid and if it's not a comment.
@@ -456,27 +922,116 @@ func validLine(line string) bool {
 }
 
 func parseJSONFormat(conf Config, line string, logitem *GLogItem) error {
-	return parseJSONString(line, func(key, value string) error {
+	fallbackHost := ""
+	var lenientErr error
+	err := parseJSONString(line, func(key, value string) error {
 		if len(value) == 0 || len(key) == 0 {
 			return nil
 		}
+		if key == conf.HostFallbackKey {
+			fallbackHost = value
+		}
 		spec, exists := conf.jsonMap[key]
+		if !exists {
+			// an array where the format expects a single value, e.g.
+			// "host": ["a", "b"] for "host": "%v"
+			spec, exists = conf.jsonMap[trimArrayIndex(key)]
+		}
 		if !exists {
 			return nil
 		}
-		return parseFormat(conf, value, logitem, spec)
+		if err := parseFormat(conf, value, logitem, spec); err != nil {
+			err = &ParseError{Key: key, Err: err}
+			if conf.LenientParsing {
+				if lenientErr == nil {
+					lenientErr = err
+				}
+				return nil
+			}
+			return err
+		}
+		return nil
 	})
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrJSONDecode, err)
+	}
+	if fallbackHost != "" && (logitem.Host == "" || isTrustedProxy(conf, logitem.Host)) {
+		if _, err := netip.ParseAddr(fallbackHost); err == nil {
+			logitem.Host = fallbackHost
+		}
+	}
+	return lenientErr
 }
 
-func parseFormat(conf Config, line string, logitem *GLogItem, fmt string) error {
-	if line == "" {
-		return errors.New("empty line")
+// trimArrayIndex removes a trailing array index from a flattened JSON key,
+// e.g. "request.host[0]" becomes "request.host".
+func trimArrayIndex(key string) string {
+	if !strings.HasSuffix(key, "]") {
+		return key
+	}
+	idx := strings.LastIndexByte(key, '[')
+	if idx == -1 {
+		return key
+	}
+	if _, err := strconv.Atoi(key[idx+1 : len(key)-1]); err != nil {
+		return key
 	}
+	return key[:idx]
+}
+
+// isTrustedProxy determines if host is in one of conf.TrustedProxies.
+func isTrustedProxy(conf Config, host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range conf.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type fmtOpKind int
+
+const (
+	// fmtOpLiteral skips one byte of the line
+	fmtOpLiteral fmtOpKind = iota
+	// fmtOpSpecial is a "~" specifier, e.g. "~h{, }"
+	fmtOpSpecial
+	// fmtOpSpecifier is a "%" specifier
+	fmtOpSpecifier
+)
+
+// fmtOp is a step of a compiled format.
+type fmtOp struct {
+	kind fmtOpKind
+	// format starting at the specifier character
+	spec  []byte
+	end   byte
+	width int
+}
+
+// compileFormat scans the format once into the steps parseFormat follows
+// for every line.
+func compileFormat(format string) ([]fmtOp, error) {
+	var ops []fmtOp
 	perc := 0
 	tilde := 0
-	lineBytesMut := []byte(line)
-	fmtBytesMut := []byte(fmt)
-	for i, r := range []byte(fmt) {
+	width := 0
+	skip := 0
+	p := []byte(format)
+	for i, r := range p {
+		if skip > 0 {
+			skip--
+			continue
+		}
 		if r == '%' {
 			perc++
 			continue
@@ -485,38 +1040,195 @@ func parseFormat(conf Config, line string, logitem *GLogItem, fmt string) error
 			tilde++
 			continue
 		}
+		if tilde > 0 && r != 0 {
+			ops = append(ops, fmtOp{kind: fmtOpSpecial, spec: p[i:]})
+			tilde = 0
+		} else if perc > 0 && r == '{' {
+			// fixed-width field, e.g. "%{3}s"
+			n, w, err := extractWidth(p[i:])
+			if err != nil {
+				return nil, err
+			}
+			width = w
+			skip = n - 1
+		} else if perc > 0 && r != 0 {
+			ops = append(ops, fmtOp{kind: fmtOpSpecifier, spec: p[i:], end: getDelim(p[i:]), width: width})
+			if isDateTimeSpec(p[i:]) {
+				// "%t" is parsed along with "%d"
+				skip = 2
+			}
+			width = 0
+			perc = 0
+		} else {
+			ops = append(ops, fmtOp{kind: fmtOpLiteral, spec: p[i:]})
+		}
+	}
+	return ops, nil
+}
+
+// compileFormats compiles every format string the config parses with.
+func compileFormats(conf *Config) error {
+	var formats []string
+	switch {
+	case conf.isJSON:
+		for _, spec := range conf.jsonMap {
+			formats = append(formats, spec)
+		}
+	case conf.isCEF:
+		formats = append(formats, conf.cefHeader...)
+		for _, spec := range conf.cefMap {
+			formats = append(formats, spec)
+		}
+	default:
+		formats = append(formats, conf.LogFormat)
+	}
+
+	conf.compiled = make(map[string][]fmtOp, len(formats))
+	for _, format := range formats {
+		ops, err := compileFormat(format)
+		if err != nil {
+			return err
+		}
+		conf.compiled[format] = ops
+	}
+	return nil
+}
+
+func parseFormat(conf Config, line string, logitem *GLogItem, fmt string) error {
+	if line == "" {
+		return errors.New("empty line")
+	}
+	ops, ok := conf.compiled[fmt]
+	if !ok {
+		// not compiled by SetupConfig, e.g. LogFormat has been changed since
+		var err error
+		if ops, err = compileFormat(fmt); err != nil {
+			return err
+		}
+	}
+	lineBytesMut := []byte(line)
+	// with LenientParsing, the first specifier error, returned at the end
+	var lenientErr error
+	skip := 0
+	for i, op := range ops {
+		if skip > 0 {
+			skip--
+			continue
+		}
 		if len(lineBytesMut) == 0 {
+			if logitem.Malformed {
+				// the rest of the line has been taken as the request
+				return lenientErr
+			}
 			return parseSpecErr(ERR_SPEC_LINE_INV, '-', nil)
 		}
 		if lineBytesMut[0] == '\n' {
-			return nil
+			return lenientErr
 		}
-		if tilde > 0 && r != 0 {
-			if len(lineBytesMut) == 0 {
-				return nil
-			}
-			fmtBytesMut = []byte(fmt)[i:]
+		switch op.kind {
+		case fmtOpSpecial:
+			fmtBytesMut := op.spec
 			if err := specialSpecifier(logitem, &lineBytesMut, &fmtBytesMut); err != nil {
 				return err
 			}
-			tilde = 0
-		} else if perc > 0 && r != 0 {
-			if len(lineBytesMut) == 0 {
-				return nil
+		case fmtOpSpecifier:
+			width := op.width
+			if width == 0 && op.end == '"' && conf.RecoverQuoting {
+				n := findClosingQuote(lineBytesMut, op.spec[1:])
+				if n > bytes.IndexByte(lineBytesMut, '"') {
+					width = n
+					logitem.RecoveredQuoting = true
+				}
 			}
-			fmtBytesMut = []byte(fmt)[i:]
-			end := getDelim(fmtBytesMut)
-			if err := parseSpecifier(conf, logitem, &lineBytesMut, fmtBytesMut, end); err != nil {
-				return err
+			if width > 0 {
+				if len(lineBytesMut) < width {
+					return parseSpecErr(ERR_SPEC_TOKN_NUL, op.spec[0], nil)
+				}
+				field := lineBytesMut[:width]
+				if err := parseSpecifier(conf, logitem, &field, op.spec, 0); err != nil {
+					if !conf.LenientParsing {
+						return err
+					}
+					if lenientErr == nil {
+						lenientErr = err
+					}
+				}
+				lineBytesMut = lineBytesMut[width:]
+			} else if err := parseSpecifier(conf, logitem, &lineBytesMut, op.spec, op.end); err != nil {
+				if !conf.LenientParsing {
+					return err
+				}
+				if lenientErr == nil {
+					lenientErr = err
+				}
 			}
-			perc = 0
-		} else if perc > 0 && r == ' ' {
-			return errors.New("space after %")
-		} else {
+			if op.spec[0] == 'U' && isOptionalQuery(ops[i+1:]) && (len(lineBytesMut) == 0 || lineBytesMut[0] != '?') {
+				// "%U?%q" given a URI without query string
+				skip = 2
+			}
+		default:
 			lineBytesMut = lineBytesMut[1:]
 		}
 	}
-	return nil
+	if conf.Strict && len(bytes.TrimRight(lineBytesMut, " \t\r\n")) > 0 {
+		return errors.New("unparsed data at the end of line: '" + string(lineBytesMut) + "'")
+	}
+	return lenientErr
+}
+
+// isOptionalQuery reports whether ops start with "?%q", which may be missing
+// after %U, e.g. for "%U?%q" given a URI without query string.
+func isOptionalQuery(ops []fmtOp) bool {
+	return len(ops) >= 2 &&
+		ops[0].kind == fmtOpLiteral && ops[0].spec[0] == '?' &&
+		ops[1].kind == fmtOpSpecifier && ops[1].spec[0] == 'q'
+}
+
+// extractWidth parses a fixed field width in braces, such as "{3}".
+//
+// On success, the number of bytes consumed and the width are returned.
+func extractWidth(p []byte) (int, int, error) {
+	end := bytes.IndexByte(p, '}')
+	if end == -1 {
+		return 0, 0, errors.New("unable to find closing brace of field width")
+	}
+	width, err := strconv.Atoi(string(p[1:end]))
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid field width '%s'", p[1:end])
+	}
+	return end + 1, width, nil
+}
+
+// findClosingQuote finds the quote that most likely closes a quoted field
+// which may contain unescaped quotes. rest is the format following the
+// specifier, starting with the closing quote. The closing quote is the first
+// one followed by the format literal up to the next specifier, or, when no
+// specifier follows, the one ending the line.
+//
+// On success, the index of the closing quote in line is returned.
+// Otherwise -1 is returned.
+func findClosingQuote(line []byte, rest []byte) int {
+	literal := rest
+	isLast := true
+	if idx := bytes.IndexByte(rest, '%'); idx != -1 {
+		literal = rest[:idx]
+		isLast = false
+	}
+	for k, c := range line {
+		if c != '"' || !bytes.HasPrefix(line[k:], literal) {
+			continue
+		}
+		if !isLast || len(bytes.TrimRight(line[k:], " \r\n")) == len(literal) {
+			return k
+		}
+	}
+	return -1
+}
+
+// isDateTimeSpec determines if the specifier is a "%d" immediately followed
+// by "%t", in which case both are parsed from one token.
+func isDateTimeSpec(p []byte) bool {
+	return len(p) >= 3 && p[0] == 'd' && p[1] == '%' && p[2] == 't'
 }
 
 func getDelim(p []byte) byte {
@@ -611,6 +1323,91 @@ func setXFFHost(logitem *GLogItem, str []byte, skips []byte, out bool) {
 	}
 }
 
+// containsFold reports whether s is in list, case-insensitively.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDecimal reports whether tkn is a non-empty string of decimal digits.
+func isDecimal(tkn []byte) bool {
+	if len(tkn) == 0 {
+		return false
+	}
+	for _, c := range tkn {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// firstHostInList returns the first IP address of a comma-separated host
+// list, such as "1.2.3.4, 5.6.7.8". If there is none, the token is returned
+// as is.
+func firstHostInList(tkn []byte) []byte {
+	if bytes.IndexByte(tkn, ',') == -1 {
+		return tkn
+	}
+	for _, host := range bytes.Split(tkn, []byte(",")) {
+		host = bytes.TrimSpace(host)
+		if net.ParseIP(string(host)) != nil {
+			return host
+		}
+	}
+	return tkn
+}
+
+// parseBracketedHost parses a host in brackets, such as "[2001:db8::1]:443".
+// The brackets are removed, and anything between the closing bracket and
+// delim is skipped. The port is returned if that is ":port", or 0.
+func parseBracketedHost(line *[]byte, delim byte) ([]byte, int) {
+	closing := bytes.IndexByte(*line, ']')
+	if closing == -1 {
+		return parseString(line, delim, 1), 0
+	}
+	tkn := (*line)[1:closing]
+	rest := (*line)[closing+1:]
+	var skipped []byte
+	if delim == 0 {
+		skipped, rest = rest, rest[len(rest):]
+	} else if i := bytes.IndexByte(rest, delim); i != -1 {
+		skipped, rest = rest[:i], rest[i:]
+	} else {
+		return nil, 0
+	}
+	*line = rest
+	port := 0
+	if portStr, ok := bytes.CutPrefix(bytes.TrimSpace(skipped), []byte(":")); ok {
+		if n, err := strconv.ParseUint(string(portStr), 10, 16); err == nil {
+			port = int(n)
+		}
+	}
+	return tkn, port
+}
+
+// stripHostPort drops the port of an IPv4 host, such as "1.2.3.4:8080", and
+// returns it, or 0 if there is none.
+func stripHostPort(tkn []byte) ([]byte, int) {
+	if bytes.IndexByte(tkn, ':') == -1 {
+		return tkn, 0
+	}
+	addrPort, err := netip.ParseAddrPort(string(tkn))
+	if err != nil || !addrPort.Addr().Is4() {
+		return tkn, 0
+	}
+	return tkn[:bytes.LastIndexByte(tkn, ':')], int(addrPort.Port())
+}
+
 func specialSpecifier(logitem *GLogItem, line *[]byte, format *[]byte) error {
 	if (*format)[0] != 'h' {
 		return nil
@@ -672,16 +1469,19 @@ func parseString(str *[]byte, delim byte, cnt int) []byte {
 	}
 
 	if end != 0 {
-		for i, ch := range pch {
+		for i := 0; i < len(pch); i++ {
+			ch := pch[i]
+			if ch == '\\' && end == '"' && i+1 < len(pch) {
+				// an escaped quote (or backslash) does not close the field
+				i++
+				continue
+			}
 			if ch == end {
 				idx++
 			}
 			if (ch == end && cnt == idx) || ch == 0 {
 				return parsedString(pch, str, i, true)
 			}
-			// if ch == '\\' && i+1 < len(pch) {
-			// 	i++
-			// }
 		}
 	} else {
 		return parsedString(pch, str, len(pch), true)
@@ -690,6 +1490,77 @@ func parseString(str *[]byte, delim byte, cnt int) []byte {
 	return nil
 }
 
+// unescapeJSONField reverses JSON string escapes and "\xHH" escapes in tkn.
+// Invalid escapes are kept as is.
+func unescapeJSONField(tkn []byte) []byte {
+	if bytes.IndexByte(tkn, '\\') == -1 {
+		return tkn
+	}
+	dest := make([]byte, 0, len(tkn))
+	for i := 0; i < len(tkn); i++ {
+		if tkn[i] != '\\' || i+1 >= len(tkn) {
+			dest = append(dest, tkn[i])
+			continue
+		}
+		switch c := tkn[i+1]; c {
+		case '"', '\\', '/':
+			dest = append(dest, c)
+		case 'b':
+			dest = append(dest, '\b')
+		case 'f':
+			dest = append(dest, '\f')
+		case 'n':
+			dest = append(dest, '\n')
+		case 'r':
+			dest = append(dest, '\r')
+		case 't':
+			dest = append(dest, '\t')
+		case 'x':
+			if i+3 < len(tkn) {
+				if b, err := strconv.ParseUint(string(tkn[i+2:i+4]), 16, 8); err == nil {
+					dest = append(dest, byte(b))
+					i += 3
+					continue
+				}
+			}
+			dest = append(dest, tkn[i], c)
+		case 'u':
+			if r, n := unescapeJSONRune(tkn[i:]); n > 0 {
+				dest = utf8.AppendRune(dest, r)
+				i += n - 1
+				continue
+			}
+			dest = append(dest, tkn[i], c)
+		default:
+			dest = append(dest, tkn[i], c)
+		}
+		i++
+	}
+	return dest
+}
+
+// unescapeJSONRune decodes a "\uXXXX" escape, or a surrogate pair of them,
+// at the start of s. It returns the rune and the number of bytes consumed,
+// or 0 if s does not start with a valid escape.
+func unescapeJSONRune(s []byte) (rune, int) {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return 0, 0
+	}
+	r1, err := strconv.ParseUint(string(s[2:6]), 16, 16)
+	if err != nil {
+		return 0, 0
+	}
+	if utf16.IsSurrogate(rune(r1)) {
+		if r2, n := unescapeJSONRune(s[6:]); n == 6 {
+			if r := utf16.DecodeRune(rune(r1), r2); r != utf8.RuneError {
+				return r, 12
+			}
+		}
+		return utf8.RuneError, 6
+	}
+	return rune(r1), 6
+}
+
 // CountMatches counts the number of matches of character c in the string s1.
 //
 // If the character is not found, 0 is returned.
@@ -718,12 +1589,59 @@ func findAlphaCount(str []byte) int {
 	return cnt
 }
 
+// parseUnitDuration parses a serve time with a unit suffix (e.g. "1.5s",
+// "250ms", "800us" or "1200000ns") into microseconds. ok is false if tkn
+// has no unit, so that the specifier's default unit applies.
+func parseUnitDuration(tkn []byte) (us uint64, ok bool) {
+	if len(tkn) == 0 || tkn[len(tkn)-1] < 'a' || tkn[len(tkn)-1] > 'z' {
+		return 0, false
+	}
+	d, err := time.ParseDuration(string(tkn))
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return uint64(d.Microseconds()), true
+}
+
+// trimZeroFraction removes a fractional part made of zeros only, e.g.
+// "1234.00" becomes "1234".
+func trimZeroFraction(tkn []byte) []byte {
+	idx := bytes.IndexByte(tkn, '.')
+	if idx <= 0 || len(bytes.TrimRight(tkn[idx+1:], "0")) > 0 {
+		return tkn
+	}
+	return tkn[:idx]
+}
+
+// countDateSpaces counts the spaces in the first fmtspcs runs of spaces of
+// str, i.e. the spaces within a date having fmtspcs spaces in its format,
+// padding included.
+func countDateSpaces(str []byte, fmtspcs int) int {
+	cnt := 0
+	runs := 0
+	for i, b := range str {
+		if b != ' ' {
+			continue
+		}
+		cnt++
+		if i+1 == len(str) || str[i+1] != ' ' {
+			runs++
+			if runs == fmtspcs {
+				break
+			}
+		}
+	}
+	return cnt
+}
+
 const (
 	SECS = 1000000
 	MILS = 1000
 )
 
-func str2time(str, fmt []byte) (*time.Time, error) {
+// str2time parses str with the given date/time format. UNIX timestamps are
+// converted to loc.
+func str2time(str, fmt []byte, loc *time.Location) (*time.Time, error) {
 	if len(str) == 0 || len(fmt) == 0 {
 		return nil, errors.New("empty time string/format")
 	}
@@ -734,66 +1652,263 @@ func str2time(str, fmt []byte) (*time.Time, error) {
 		if err != nil {
 			return nil, err
 		}
-		var seconds int64
+		var seconds, nanos int64
 		if us {
 			seconds = int64(ts / SECS)
+			nanos = int64(ts%SECS) * 1000
 		} else if ms {
 			seconds = int64(ts / MILS)
+			nanos = int64(ts%MILS) * 1000000
 		} else {
 			seconds = int64(ts)
 		}
-		t := time.Unix(seconds, 0)
+		t := time.Unix(seconds, nanos).In(loc)
 
 		return &t, nil
 	}
+	if bytes.Equal(fmt, []byte("%s")) {
+		return secs2time(str, loc)
+	}
 
+	var nanos int
+	if bytes.Contains(fmt, []byte("%f")) {
+		// timefmt reads 6 digits at most for %f, e.g. Traefik logs 9
+		str, nanos = cutSubMicros(str)
+	}
 	t, err := timefmt.Parse(string(str), string(fmt))
 	if err != nil {
 		return nil, err
 	}
+	t = t.Add(time.Duration(nanos))
+	return &t, nil
+}
+
+// cutSubMicros removes the digits beyond microseconds from the fractional
+// seconds of a time token, and returns them in nanoseconds.
+func cutSubMicros(str []byte) ([]byte, int) {
+	i := bytes.LastIndexByte(str, '.')
+	if i == -1 {
+		return str, 0
+	}
+	end := i + 1
+	for end < len(str) && isDigit(str[end]) {
+		end++
+	}
+	if end-i-1 <= 6 {
+		return str, 0
+	}
+	nanos, _ := fracNanos(str[i+1 : end])
+	return append(str[:i+7:i+7], str[end:]...), nanos % 1000
+}
+
+// fracNanos parses the digits of fractional seconds (after the '.') into
+// nanoseconds. Digits beyond nanoseconds are dropped.
+func fracNanos(digits []byte) (int, error) {
+	if len(digits) > 9 {
+		digits = digits[:9]
+	}
+	nanos, err := strconv.ParseUint(string(digits), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	for range 9 - len(digits) {
+		nanos *= 10
+	}
+	return int(nanos), nil
+}
+
+// secs2time parses a UNIX timestamp in seconds with an optional fractional
+// part, such as "1646861401.5241024". Digits beyond nanoseconds are dropped.
+func secs2time(str []byte, loc *time.Location) (*time.Time, error) {
+	intPart, fracPart, hasFrac := bytes.Cut(str, []byte("."))
+	seconds, err := strconv.ParseInt(string(intPart), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if !hasFrac {
+		t := time.Unix(seconds, 0).In(loc)
+		return &t, nil
+	}
+	nanos, err := fracNanos(fracPart)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(seconds, int64(nanos)).In(loc)
 	return &t, nil
 }
 
+// trimDateBrackets drops a leading '[' from the line when the timestamp
+// brackets are optional.
+func trimDateBrackets(conf Config, line *[]byte) {
+	if conf.OptionalDateBrackets && len(*line) > 0 && (*line)[0] == '[' {
+		*line = (*line)[1:]
+	}
+}
+
+// trimDateToken drops a trailing ']' from the token when the timestamp
+// brackets are optional.
+func trimDateToken(conf Config, tkn []byte) []byte {
+	if conf.OptionalDateBrackets {
+		return bytes.TrimSuffix(tkn, []byte("]"))
+	}
+	return tkn
+}
+
+// minTimeLen estimates the shortest token the given strftime-like format can
+// match, by formatting a date whose month and weekday names are the shortest,
+// and allowing two-digit numbers to be given as one digit (e.g. "Dec 2").
+// Timestamp formats return 0.
+func minTimeLen(format string) int {
+	if format == "" || strings.Contains(format, "%s") ||
+		strings.Contains(format, "%f") || strings.Contains(format, "%*") {
+		return 0
+	}
+	// Friday, 12 May 2000
+	ref := time.Date(2000, time.May, 12, 10, 10, 10, 0, time.UTC)
+	n := len(timefmt.Format(ref, format))
+	for _, spec := range []string{"%d", "%e", "%m", "%H", "%I", "%M", "%S"} {
+		n -= strings.Count(format, spec)
+	}
+	return n
+}
+
+// looksLikeTime reports whether a date token is obviously a time, i.e. it
+// has ':' while the date format has none.
+func looksLikeTime(tkn []byte, dateFormat string) bool {
+	return bytes.IndexByte(tkn, ':') != -1 && !strings.Contains(dateFormat, ":")
+}
+
+// looksLikeDate reports whether a time token is obviously a date, i.e. it
+// has '/' or '-' but no ':' while the time format has ':'.
+func looksLikeDate(tkn []byte, timeFormat string) bool {
+	return strings.Contains(timeFormat, ":") && bytes.IndexByte(tkn, ':') == -1 &&
+		bytes.ContainsAny(tkn, "/-")
+}
+
 func setDate(logitem *GLogItem, t *time.Time) {
 	logitem.Dt = logitem.Dt.AddDate(t.Year()-logitem.Dt.Year(), int(t.Month())-int(logitem.Dt.Month()), t.Day()-logitem.Dt.Day())
 }
 
+// cutFraction removes the fractional seconds (a '.' between digits and the
+// digits after it) from a time token, and returns them in nanoseconds.
+// Digits beyond nanoseconds are dropped.
+func cutFraction(tkn []byte) ([]byte, int) {
+	for i := 1; i+1 < len(tkn); i++ {
+		if tkn[i] != '.' || !isDigit(tkn[i-1]) || !isDigit(tkn[i+1]) {
+			continue
+		}
+		end := i + 1
+		for end < len(tkn) && isDigit(tkn[end]) {
+			end++
+		}
+		nanos, _ := fracNanos(tkn[i+1 : end])
+		return append(tkn[:i:i], tkn[end:]...), nanos
+	}
+	return tkn, 0
+}
+
 func setTime(logitem *GLogItem, t *time.Time) {
 	logitem.Dt = time.Date(logitem.Dt.Year(), logitem.Dt.Month(), logitem.Dt.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), logitem.Dt.Location())
 }
 
+// offsetLocation returns the location of the offset parsed by %z. Zero
+// offsets (+0000 and -0000) become UTC, and timefmt already reads "+08" as
+// "+08:00".
+func offsetLocation(t *time.Time) *time.Location {
+	if _, offset := t.Zone(); offset != 0 {
+		return time.FixedZone("", offset)
+	}
+	return time.UTC
+}
+
+// timeLocation returns the location given by the time format for t: the
+// offset parsed by %z if useOffset, or else the zone abbreviation parsed by
+// %Z as given by conf.ZoneOffsets. It returns nil otherwise (e.g. for an
+// unknown abbreviation), leaving the time in conf.Timezone.
+func timeLocation(conf Config, t *time.Time, useOffset bool) *time.Location {
+	if conf.timeOffset && useOffset {
+		return offsetLocation(t)
+	}
+	if conf.timeZone {
+		name, _ := t.Zone()
+		if offset, ok := conf.ZoneOffsets[name]; ok {
+			return time.FixedZone(name, offset)
+		}
+	}
+	return nil
+}
+
+// fullTime returns a full timestamp (%x and %X) parsed as t. Unlike %t, it
+// always keeps the offset (%z) or zone (%Z) of its time format, e.g. "Z"
+// for the ENVOY preset, and is in conf.Timezone otherwise.
+func fullTime(conf Config, t *time.Time) time.Time {
+	loc := timeLocation(conf, t, true)
+	if loc == nil {
+		loc = &conf.Timezone
+	}
+	return inLocation(*t, loc)
+}
+
+// setLocation keeps the wall clock of Dt but moves it to loc.
+func setLocation(logitem *GLogItem, loc *time.Location) {
+	logitem.Dt = inLocation(logitem.Dt, loc)
+}
+
+// inLocation returns the wall clock of t in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// takeMalformedReq consumes the rest of the line as the request, when the
+// request field cannot be delimited (e.g. a truncated line).
+func takeMalformedReq(logitem *GLogItem, line *[]byte) {
+	logitem.Req = string(bytes.TrimSpace(*line))
+	logitem.Malformed = true
+	*line = nil
+}
+
 func parseReq(conf Config, line []byte, method, protocol *string) []byte {
 	var req, request, dreq []byte
 	var meth, proto []byte
 
-	meth = extractMethod(line)
+	meth = extractMethod(conf, line)
+	// the method must be a whole word, so that the URI, which may contain
+	// unencoded spaces, is anchored between it and the protocol
+	if meth != nil && (len(line) == len(meth) || line[len(meth)] != ' ') {
+		meth = nil
+	}
 
 	// couldn't find a method, so use the whole request line
 	if meth == nil {
 		request = line
 	} else {
 		// method found, attempt to parse request
-		req = line[len(meth):]
+		req = bytes.TrimRight(line[len(meth):], " ")
 		ptr := bytes.LastIndexByte(req, ' ')
 		if ptr != -1 {
-			proto = extractProtocol(req[ptr+1:])
+			proto = extractProtocol(conf, req[ptr+1:])
 		}
 		if ptr == -1 || proto == nil {
 			return []byte("-")
 		}
 
+		// no URI between the method and the protocol, e.g. "GET HTTP/1.1"
 		req = bytes.TrimSpace(req)
-		if len(req) == 0 {
+		ptr = bytes.LastIndexByte(req, ' ')
+		if ptr == -1 {
 			return []byte("-")
 		}
 
-		request = req[:bytes.LastIndexByte(req, ' ')]
+		request = req[:ptr]
 
 		// AppendMethod and AppendProtocol are enabled by default
 		*method = string(bytes.ToUpper(meth))
 		*protocol = string(bytes.ToUpper(proto))
 	}
 
+	if conf.DisableURLDecode {
+		return request
+	}
 	dreq = decodeURL(conf, request)
 	if dreq == nil {
 		return request
@@ -809,16 +1924,24 @@ func decodeURL(conf Config, s []byte) []byte {
 	if len(s) == 0 {
 		return nil
 	}
+	if conf.DisableURLDecode {
+		return bytes.TrimSpace(s)
+	}
+
+	unescape := url.QueryUnescape
+	if conf.DecodeMode == RawURLDecode {
+		unescape = url.PathUnescape
+	}
 
 	// First decoding
-	decoded, err := url.QueryUnescape(string(s))
+	decoded, err := unescape(string(s))
 	if err != nil {
 		return nil
 	}
 
 	// Double decoding if configured
 	if conf.DoubleDecodeEnabled {
-		decoded, err = url.QueryUnescape(decoded)
+		decoded, err = unescape(decoded)
 		if err != nil {
 			return nil
 		}
@@ -839,43 +1962,113 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 	// fmt.Println(string(p), "|", string(*line), "|", string(end), "|")
 	switch p {
 	case 'd':
+		dateFormat := conf.DateFormat
+		dateTime := isDateTimeSpec(specifier)
+		if dateTime {
+			// "%d%t", date and time share a single token
+			dateFormat += conf.TimeFormat
+			end = getDelim(specifier[2:])
+		}
+		trimDateBrackets(conf, line)
 		// Take "Dec  2" and "Nov 22" cases into consideration
-		fmtspcs := countMatches([]byte(conf.DateFormat), ' ')
-		pch := bytes.IndexByte(*line, ' ')
-		dspc := 0
-		if fmtspcs > 0 && pch != -1 {
-			dspc = findAlphaCount((*line)[pch:])
+		fmtspcs := countMatches([]byte(dateFormat), ' ')
+		var cnt int
+		if end == ' ' && fmtspcs > 0 {
+			// padding may be anywhere, e.g. "2023 Dec  2" for "%Y %b %e"
+			cnt = countDateSpaces(*line, fmtspcs) + 1
+		} else {
+			pch := bytes.IndexByte(*line, ' ')
+			dspc := 0
+			if fmtspcs > 0 && pch != -1 {
+				dspc = findAlphaCount((*line)[pch:])
+			}
+			cnt = max(dspc, fmtspcs) + 1
 		}
-		tkn := parseString(line, end, max(dspc, fmtspcs)+1)
+		tkn := parseString(line, end, cnt)
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		tm, err := str2time(tkn, []byte(conf.DateFormat))
+		tkn = trimDateToken(conf, tkn)
+		if !dateTime && looksLikeTime(tkn, dateFormat) {
+			return parseSpecErr(ERR_SPEC_TOKN_SWP, p, tkn)
+		}
+		if len(tkn) < conf.dateMinLen {
+			return parseSpecErr(ERR_SPEC_TOKN_LEN, p, tkn)
+		}
+		tm, err := str2time(tkn, []byte(dateFormat), &conf.Timezone)
 		if err != nil {
 			return err
 		}
 		setDate(logitem, tm)
+		if dateTime {
+			setTime(logitem, tm)
+		}
 	case 't':
+		trimDateBrackets(conf, line)
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		tm, err := str2time(tkn, []byte(conf.TimeFormat))
+		tkn = trimDateToken(conf, tkn)
+		if looksLikeDate(tkn, conf.TimeFormat) {
+			return parseSpecErr(ERR_SPEC_TOKN_SWP, p, tkn)
+		}
+		var nanos int
+		if !strings.Contains(conf.TimeFormat, "%f") && !strings.Contains(conf.TimeFormat, ".") {
+			// e.g. "10:11:12.345" for "%H:%M:%S"
+			tkn, nanos = cutFraction(tkn)
+		}
+		tm, err := str2time(tkn, []byte(conf.TimeFormat), &conf.Timezone)
 		if err != nil {
 			return err
 		}
+		if nanos > 0 {
+			*tm = tm.Add(time.Duration(nanos))
+		}
 		setTime(logitem, tm)
+		if loc := timeLocation(conf, tm, conf.UseLogTimezone); loc != nil {
+			setLocation(logitem, loc)
+		}
 	case 'x':
+		trimDateBrackets(conf, line)
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		tm, err := str2time(tkn, []byte(conf.TimeFormat))
+		tkn = trimDateToken(conf, tkn)
+		tm, err := str2time(tkn, []byte(conf.TimeFormat), &conf.Timezone)
 		if err != nil {
 			return err
 		}
-		setDate(logitem, tm)
-		setTime(logitem, tm)
+		logitem.Dt = fullTime(conf, tm)
+	case 'z':
+		// goaccessfmt extension
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		if conf.UseLogTimezone {
+			tm, err := timefmt.Parse(string(tkn), "%z")
+			if err != nil {
+				return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+			}
+			setLocation(logitem, offsetLocation(&tm))
+		}
+	case 'X':
+		if !logitem.DtEnd.IsZero() {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		trimDateBrackets(conf, line)
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		tkn = trimDateToken(conf, tkn)
+		tm, err := str2time(tkn, []byte(conf.TimeFormat), &conf.Timezone)
+		if err != nil {
+			return err
+		}
+		logitem.DtEnd = fullTime(conf, tm)
 	case 'v':
 		if logitem.VHost != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -884,7 +2077,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.VHost = string(tkn)
+		logitem.VHost = string(conf.transform(p, tkn))
 	case 'e':
 		if logitem.Userid != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -893,31 +2086,52 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.Userid = string(tkn)
+		logitem.Userid = string(conf.transform(p, tkn))
 	case 'C':
-		if logitem.CacheStatus != "" {
+		if logitem.CacheStatus != "" || logitem.CacheStatusRaw != "" {
 			return handleDefaultCaseToken(line, specifier)
 		}
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
+		tkn = conf.transform(p, tkn)
 		switch strings.ToUpper(string(tkn)) {
-		case "MISS", "BYPASS", "EXPIRED", "STALE", "UPDATING", "REVALIDATED", "HIT":
+		case "MISS", "BYPASS", "EXPIRED", "STALE", "UPDATING", "REVALIDATED", "HIT",
+			"DYNAMIC", "NONE":
 			logitem.CacheStatus = string(tkn)
+		case "-":
+			// not logged
+		default:
+			if containsFold(CloudFrontCacheStatuses, string(tkn)) || containsFold(conf.CacheStatuses, string(tkn)) {
+				logitem.CacheStatus = string(tkn)
+			} else {
+				logitem.CacheStatusRaw = string(tkn)
+			}
 		}
 	case 'h':
 		if logitem.Host != "" {
 			return handleDefaultCaseToken(line, specifier)
 		}
+		var tkn []byte
+		port := 0
 		if (*line)[0] == '[' && len(*line) >= 2 {
-			end = ']'
+			tkn, port = parseBracketedHost(line, end)
+		} else {
+			tkn = parseString(line, end, 1)
 		}
-		tkn := parseString(line, end, 1)
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.Host = string(tkn)
+		if conf.SplitHostList {
+			tkn = firstHostInList(tkn)
+		}
+		tkn, hostPort := stripHostPort(tkn)
+		if hostPort != 0 {
+			port = hostPort
+		}
+		logitem.Host = string(conf.transform(p, tkn))
+		logitem.Port = port
 	case 'm':
 		if logitem.Method != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -926,7 +2140,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		meth := extractMethod(tkn)
+		meth := extractMethod(conf, tkn)
 		if meth == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
 		}
@@ -936,15 +2150,33 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if logitem.Req != "" {
 			return handleDefaultCaseToken(line, specifier)
 		}
+		if end == '?' && bytes.HasPrefix(specifier, []byte("U?%q")) {
+			// "%U?%q" without query string ends where the query would, see
+			// isOptionalQuery. Only a '?' before that delimiter starts a query.
+			qEnd := getDelim(specifier[3:])
+			q := bytes.IndexByte(*line, '?')
+			if q == -1 || (qEnd != 0 && bytes.IndexByte((*line)[:q], qEnd) != -1) {
+				end = qEnd
+			}
+		}
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
+			if conf.RecoverMalformedReq {
+				takeMalformedReq(logitem, line)
+				return nil
+			}
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
 		req := decodeURL(conf, tkn)
 		if req == nil {
+			if conf.RecoverMalformedReq {
+				logitem.Req = string(tkn)
+				logitem.Malformed = true
+				return nil
+			}
 			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
 		}
-		logitem.Req = string(req)
+		logitem.Req = string(conf.transform(p, req))
 	case 'q':
 		if logitem.Qstr != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -957,7 +2189,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if qstr == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
 		}
-		logitem.Qstr = string(qstr)
+		logitem.Qstr = string(conf.transform(p, qstr))
 	case 'H':
 		if logitem.Protocol != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -966,7 +2198,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		proto := extractProtocol(tkn)
+		proto := extractProtocol(conf, tkn)
 		if proto == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
 		}
@@ -978,10 +2210,19 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		}
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
+			if conf.RecoverMalformedReq {
+				takeMalformedReq(logitem, line)
+				return nil
+			}
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
 		req := parseReq(conf, tkn, &logitem.Method, &logitem.Protocol)
-		logitem.Req = string(req)
+		// a logged "-" (e.g. a 408 without request line) is not malformed
+		if conf.RecoverMalformedReq && bytes.Equal(req, []byte("-")) && !bytes.Equal(bytes.TrimSpace(tkn), []byte("-")) {
+			req = tkn
+			logitem.Malformed = true
+		}
+		logitem.Req = string(conf.transform(p, req))
 	case 's':
 		if logitem.Status >= 0 {
 			return handleDefaultCaseToken(line, specifier)
@@ -990,11 +2231,35 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		status, err := strconv.ParseInt(string(tkn), 10, 32)
+		// status may be followed by a reason phrase, e.g. "404 Not Found",
+		// or by the upstream status, e.g. "502 200" or "502/200"
+		code, reason, _ := bytes.Cut(tkn, []byte(" "))
+		reason = bytes.TrimSpace(reason)
+		code, upstream, hasUpstream := bytes.Cut(code, []byte("/"))
+		if !hasUpstream && (isDecimal(reason) || bytes.Equal(reason, []byte("-"))) {
+			upstream, reason, hasUpstream = reason, nil, true
+		}
+		if bytes.Equal(upstream, []byte("-")) {
+			// nginx writes "-" when there is no upstream
+			hasUpstream = false
+		}
+		code = bytes.TrimPrefix(code, []byte("+"))
+		if !isDecimal(code) || (hasUpstream && !isDecimal(upstream)) {
+			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+		}
+		status, err := strconv.ParseInt(string(code), 10, 32)
 		if err != nil {
-			return err
+			return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+		}
+		if hasUpstream {
+			upstreamStatus, err := strconv.ParseInt(string(upstream), 10, 32)
+			if err != nil {
+				return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+			}
+			logitem.UpstreamStatus = int(upstreamStatus)
 		}
 		logitem.Status = int(status)
+		logitem.StatusReason = string(reason)
 	case 'b':
 		if logitem.RespSize > 0 {
 			return handleDefaultCaseToken(line, specifier)
@@ -1003,11 +2268,32 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		bandw, err := strconv.ParseUint(string(tkn), 10, 64)
+		// JSON encoders may write sizes as floats, e.g. 1234.0
+		bandw, err := strconv.ParseUint(string(trimZeroFraction(tkn)), 10, 64)
 		if err != nil {
+			if conf.Strict && !bytes.Equal(tkn, []byte("-")) {
+				return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+			}
 			bandw = 0
 		}
 		logitem.RespSize = bandw
+	case 'B':
+		// goaccessfmt extension
+		if logitem.ReqSize > 0 {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		size, err := strconv.ParseUint(string(trimZeroFraction(tkn)), 10, 64)
+		if err != nil {
+			if conf.Strict && !bytes.Equal(tkn, []byte("-")) {
+				return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+			}
+			size = 0
+		}
+		logitem.ReqSize = size
 	case 'R':
 		if logitem.Ref != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -1015,19 +2301,24 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		tkn := parseString(line, end, 1)
 		if tkn == nil {
 			tkn = []byte("-")
+		} else if conf.JSONEscapedFields {
+			tkn = unescapeJSONField(tkn)
 		}
-		logitem.Ref = string(tkn)
+		logitem.Ref = string(conf.transform(p, tkn))
 	case 'u':
 		if logitem.Agent != "" {
 			return handleDefaultCaseToken(line, specifier)
 		}
 		tkn := parseString(line, end, 1)
 		if tkn != nil {
+			if conf.JSONEscapedFields {
+				tkn = unescapeJSONField(tkn)
+			}
 			tkn = decodeURL(conf, tkn)
 		} else {
 			tkn = []byte("-")
 		}
-		logitem.Agent = string(tkn)
+		logitem.Agent = string(conf.transform(p, tkn))
 	case 'L':
 		if logitem.ServeTime > 0 {
 			return handleDefaultCaseToken(line, specifier)
@@ -1036,6 +2327,10 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
+		if us, ok := parseUnitDuration(tkn); ok {
+			logitem.ServeTime = us
+			break
+		}
 		serveSecs, err := strconv.ParseUint(string(tkn), 10, 64)
 		if err != nil {
 			serveSecs = 0
@@ -1049,10 +2344,15 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
+		if us, ok := parseUnitDuration(tkn); ok {
+			logitem.ServeTime = us
+			break
+		}
 		var serveSecs float64
 		var serveSecsUll uint64
 		var err error
-		if bytes.IndexByte(tkn, '.') != -1 {
+		isFloat := bytes.IndexByte(tkn, '.') != -1
+		if isFloat {
 			serveSecs, err = strconv.ParseFloat(string(tkn), 64)
 		} else {
 			serveSecsUll, err = strconv.ParseUint(string(tkn), 10, 64)
@@ -1060,8 +2360,14 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		}
 		if err != nil {
 			serveSecs = 0
+			serveSecsUll = 0
+		}
+		if conf.IntServeTimeNanos && !isFloat {
+			// integer is nanoseconds
+			logitem.ServeTime = serveSecsUll / 1000
+		} else {
+			logitem.ServeTime = uint64(serveSecs * 1000000)
 		}
-		logitem.ServeTime = uint64(serveSecs * 1000000)
 	case 'D':
 		if logitem.ServeTime > 0 {
 			return handleDefaultCaseToken(line, specifier)
@@ -1070,6 +2376,10 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
+		if us, ok := parseUnitDuration(tkn); ok {
+			logitem.ServeTime = us
+			break
+		}
 		serveTime, err := strconv.ParseUint(string(tkn), 10, 64)
 		if err != nil {
 			serveTime = 0
@@ -1096,7 +2406,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.TLSCypher = string(tkn)
+		logitem.TLSCypher = string(conf.transform(p, tkn))
 	case 'K':
 		if logitem.TLSType != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -1105,7 +2415,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.TLSType = string(tkn)
+		logitem.TLSType = string(conf.transform(p, tkn))
 	case 'M':
 		if logitem.MimeType != "" {
 			return handleDefaultCaseToken(line, specifier)
@@ -1114,7 +2424,7 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.MimeType = string(tkn)
+		logitem.MimeType = string(conf.transform(p, tkn))
 	case '~':
 		s := *line
 		for i, r := range s {
@@ -1132,51 +2442,224 @@ func parseSpecifier(conf Config, logitem *GLogItem, line *[]byte, specifier []by
 		if tkn == nil {
 			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
 		}
-		logitem.Server = string(tkn)
+		logitem.Server = string(conf.transform(p, tkn))
+	case 'i':
+		// goaccessfmt extension
+		if logitem.RequestID != "" {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		logitem.RequestID = string(conf.transform(p, tkn))
+	case 'I':
+		// goaccessfmt extension
+		if logitem.TraceID != "" {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		logitem.TraceID = string(conf.transform(p, tkn))
+	case 'g':
+		// goaccessfmt extension
+		if logitem.GeoLocation != "" {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		logitem.GeoLocation = string(conf.transform(p, tkn))
+	case 'w':
+		// goaccessfmt extension
+		// HAProxy style timing group in milliseconds, e.g. "Tq/Tw/Tc/Tr/Tt"
+		if logitem.Timings != nil {
+			return handleDefaultCaseToken(line, specifier)
+		}
+		tkn := parseString(line, end, 1)
+		if tkn == nil {
+			return parseSpecErr(ERR_SPEC_TOKN_NUL, p, tkn)
+		}
+		parts := bytes.Split(tkn, []byte("/"))
+		timings := make([]uint64, 0, len(parts))
+		for _, part := range parts {
+			// "+" is prepended with "option logasap", -1 means not reached
+			ms, err := strconv.ParseInt(string(bytes.TrimPrefix(part, []byte("+"))), 10, 64)
+			if err != nil {
+				return parseSpecErr(ERR_SPEC_TOKN_INV, p, tkn)
+			}
+			timings = append(timings, uint64(max(ms, 0)))
+		}
+		logitem.Timings = timings
+		if logitem.ServeTime == 0 {
+			logitem.ServeTime = timings[len(timings)-1] * 1000
+		}
+	case '^':
+		// ignore the field, up to the delimiter
+		parseString(line, end, 1)
 	default:
 		return handleDefaultCaseToken(line, specifier)
 	}
 	return nil
 }
 
+// SetupConfigMulti returns primary with fallbacks attached. ParseLine tries
+// the fallbacks in order when a line does not match primary, and returns
+// the first success; set distinct FormatName to tell which one matched.
+// Fallbacks of the fallbacks are ignored.
+func SetupConfigMulti(primary Config, fallbacks ...Config) Config {
+	primary.fallbacks = make([]Config, len(fallbacks))
+	for i, fallback := range fallbacks {
+		fallback.fallbacks = nil
+		primary.fallbacks[i] = fallback
+	}
+	return primary
+}
+
 func ParseLine(conf Config, line string) (*GLogItem, error) {
 	if !validLine(line) {
 		return nil, errors.New("invalid line")
 	}
+	logitem, err := parseLine(conf, line)
+	if err == nil {
+		return logitem, nil
+	}
+	for _, fallback := range conf.fallbacks {
+		if logitem, fallbackErr := parseLine(fallback, line); fallbackErr == nil {
+			return logitem, nil
+		}
+	}
+	return logitem, err
+}
+
+// LineError is the error of a line that fails to parse in ParseLines.
+type LineError struct {
+	// Line is the index of the line, from 0, in the input of ParseLines or
+	// ParseConcurrent
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ParseLines parses every line with conf, instead of stopping at the first
+// failure. It returns the items of the lines that parse, in order, and a
+// *LineError for each line that does not. Empty and comment lines are
+// skipped.
+func ParseLines(conf Config, lines []string) ([]*GLogItem, []error) {
+	var logitems []*GLogItem
+	var errs []error
+	for i, line := range lines {
+		if !validLine(line) {
+			continue
+		}
+		logitem, err := ParseLine(conf, line)
+		if err != nil {
+			errs = append(errs, &LineError{Line: i, Err: err})
+			continue
+		}
+		logitems = append(logitems, logitem)
+	}
+	return logitems, errs
+}
+
+// ParseLineInto is ParseLine filling out, which is reset first, instead of
+// allocating a new item, for parsing many lines with the same item. On
+// error, out holds the partially parsed item with conf.LenientParsing, and
+// is unspecified otherwise.
+func ParseLineInto(conf Config, line string, out *GLogItem) error {
+	out.Reset()
+	if !validLine(line) {
+		return errors.New("invalid line")
+	}
+	err := parseLineInto(conf, line, out)
+	if err == nil || len(conf.fallbacks) == 0 {
+		return err
+	}
+	var logitem GLogItem
+	for _, fallback := range conf.fallbacks {
+		if parseLineInto(fallback, line, &logitem) == nil {
+			*out = logitem
+			return nil
+		}
+	}
+	return err
+}
+
+// Reset zeroes every field of the item.
+func (a *GLogItem) Reset() {
+	*a = GLogItem{}
+}
+
+func parseLine(conf Config, line string) (*GLogItem, error) {
+	var logitem GLogItem
+	if err := parseLineInto(conf, line, &logitem); err != nil {
+		if conf.LenientParsing {
+			return &logitem, err
+		}
+		return nil, err
+	}
+	return &logitem, nil
+}
+
+func parseLineInto(conf Config, line string, logitem *GLogItem) error {
 	// init logitem
-	logitem := GLogItem{}
+	logitem.Reset()
 	logitem.Status = -1
+	if !conf.status && conf.DefaultStatus != 0 {
+		logitem.Status = conf.DefaultStatus
+	}
 	logitem.Dt = logitem.Dt.In(&conf.Timezone)
+	logitem.FormatName = conf.FormatName
 
 	var err error
 	if conf.isJSON {
-		err = parseJSONFormat(conf, line, &logitem)
+		err = parseJSONFormat(conf, line, logitem)
+	} else if conf.isCEF {
+		err = parseCEFFormat(conf, line, logitem)
+	} else if conf.ApacheErrorLog {
+		err = parseApacheErrorLine(conf, line, logitem)
 	} else {
-		err = parseFormat(conf, line, &logitem, conf.LogFormat)
+		err = parseFormat(conf, line, logitem, conf.LogFormat)
 	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if conf.NormalizeReq != (ReqNormalization{}) {
+		logitem.RawReq = logitem.Req
+		logitem.Req = logitem.NormalizedReq(conf.NormalizeReq)
 	}
+	return nil
+}
 
-	return &logitem, nil
+// String returns a single-line representation of the item for logging and
+// debugging. The field order is stable, and Dt is in RFC 3339.
+func (a GLogItem) String() string {
+	return fmt.Sprintf("Host=%q Port=%d Dt=%s VHost=%q Userid=%q CacheStatus=%q Method=%q Req=%q Qstr=%q Protocol=%q Status=%d RespSize=%d ReqSize=%d Ref=%q Agent=%q ServeTime=%d TLSCypher=%q TLSType=%q MimeType=%q GeoLocation=%q",
+		a.Host, a.Port, a.Dt.Format(time.RFC3339Nano), a.VHost, a.Userid, a.CacheStatus,
+		a.Method, a.Req, a.Qstr, a.Protocol, a.Status, a.RespSize, a.ReqSize,
+		a.Ref, a.Agent, a.ServeTime, a.TLSCypher, a.TLSType, a.MimeType,
+		a.GeoLocation)
+}
+
+// Duration returns DtEnd - Dt, or 0 if either is not set.
+func (a GLogItem) Duration() time.Duration {
+	if a.Dt.IsZero() || a.DtEnd.IsZero() {
+		return 0
+	}
+	return a.DtEnd.Sub(a.Dt)
 }
 
+// PrintLog prints the item to stdout, see GLogItem.String.
 func PrintLog(logitem *GLogItem) {
-	fmt.Println("Host", logitem.Host)
-	fmt.Println("time.Time", logitem.Dt)
-	fmt.Println("VHost", logitem.VHost)
-	fmt.Println("Userid", logitem.Userid)
-	fmt.Println("CacheStatus", logitem.CacheStatus)
-	fmt.Println("Method", logitem.Method)
-	fmt.Println("Req", logitem.Req)
-	fmt.Println("Qstr", logitem.Qstr)
-	fmt.Println("Protocol", logitem.Protocol)
-	fmt.Println("Status", logitem.Status)
-	fmt.Println("RespSize", logitem.RespSize)
-	fmt.Println("Ref", logitem.Ref)
-	fmt.Println("Agent", logitem.Agent)
-	fmt.Println("ServeTime", logitem.ServeTime)
-	fmt.Println("TLSCypher", logitem.TLSCypher)
-	fmt.Println("TLSType", logitem.TLSType)
-	fmt.Println("MimeType", logitem.MimeType)
+	fmt.Println(logitem.String())
 }
@@ -1,6 +1,13 @@
 package goaccessfmt_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -102,7 +109,7 @@ func TestCaddy(t *testing.T) {
 	}
 	expectedLogitem := goaccessfmt.GLogItem{
 		Host:      "127.0.0.1",
-		Dt:        time.Date(2022, 3, 9, 21, 30, 1, 0, locationUTC),
+		Dt:        time.Date(2022, 3, 9, 21, 30, 1, 524102400, locationUTC),
 		VHost:     "localhost",
 		Method:    "GET",
 		Req:       "/",
@@ -120,6 +127,29 @@ func TestCaddy(t *testing.T) {
 	}
 }
 
+func TestCaddyPresetsFraction(t *testing.T) {
+	line := `{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/"},"duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Content-Length":["10900"]}}`
+	want := time.Date(2022, 3, 9, 21, 30, 1, 524102400, time.UTC)
+	for _, name := range []string{"caddy", "caddy_content_length", "caddy_extended"} {
+		logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !logitem.Dt.Equal(want) {
+			t.Errorf("%s: want (%v), get (%v)", name, want, logitem.Dt)
+		}
+	}
+}
+
 func TestXFF(t *testing.T) {
 	logfmt := `~h{ } %^[%d:%t %^] "%r" %s %b "%R" "%u"`
 	datefmt := "%d/%b/%Y"
@@ -152,7 +182,7 @@ func TestXFF(t *testing.T) {
 
 func TestServerExtension(t *testing.T) {
 	// logfmt := goaccessfmt.Logs.Caddy
-	logfmt := `{ "server": "%S", "ts": "%x.%^", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U", "headers": {"User-Agent": ["%u"], "Referer": ["%R"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "size": "%b","status": "%s", "resp_headers": { "Content-Type": ["%M"] } }`
+	logfmt := `{ "server": "%S", "ts": "%x", "request": { "client_ip": "%h", "proto":"%H", "method": "%m", "host": "%v", "uri": "%U", "headers": {"User-Agent": ["%u"], "Referer": ["%R"] }, "tls": { "cipher_suite":"%k", "proto": "%K" } }, "duration": "%T", "size": "%b","status": "%s", "resp_headers": { "Content-Type": ["%M"] } }`
 	datefmt := goaccessfmt.Dates.Sec
 	timefmt := goaccessfmt.Times.Sec
 	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
@@ -201,3 +231,2202 @@ func TestMirrorNginxJSONFormat(t *testing.T) {
 		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
 	}
 }
+
+func TestOptionalDateBrackets(t *testing.T) {
+	logfmt := `%h %^ %^ %d:%t %^ "%r" %s %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+	conf.OptionalDateBrackets = true
+
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "114.5.1.4",
+		Dt:       time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:      "/index.html",
+		Method:   "GET",
+		Protocol: "HTTP/1.1",
+		Status:   200,
+		RespSize: 568,
+	}
+	lines := []string{
+		`114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`,
+		`114.5.1.4 - - 11/Jun/2023:11:23:45 +0800 "GET /index.html HTTP/1.1" 200 568`,
+	}
+	for _, line := range lines {
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !logitem.Equal(expectedLogitem) {
+			t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+		}
+	}
+}
+
+func TestFixedWidthField(t *testing.T) {
+	logfmt := `%h %^[%d:%t %^] "%r" %{3}s%b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 404568`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "114.5.1.4",
+		Dt:       time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:      "/index.html",
+		Method:   "GET",
+		Protocol: "HTTP/1.1",
+		Status:   404,
+		RespSize: 568,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestCaddyContentLength(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("caddy_content_length")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := []string{
+		`{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/"},"duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Content-Length":["4321"]}}`,
+		`{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/"},"duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Content-Length":[4321]}}`,
+	}
+	for _, line := range lines {
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.RespSize != 4321 {
+			t.Errorf("want (%v), get (%v)", 4321, logitem.RespSize)
+		}
+	}
+}
+
+func TestAdjacentSpecifiers(t *testing.T) {
+	_, err := goaccessfmt.SetupConfig(`%h %^[%d:%t %^] "%r" %s%b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err == nil {
+		t.Error("want error for adjacent specifiers, get nil")
+	}
+	_, err = goaccessfmt.SetupConfig(`%h %^[%d:%t %^] "%r" %{3}s%b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCaddyIntegerDuration(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("caddy")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+	conf.IntServeTimeNanos = true
+
+	cases := []struct {
+		duration  string
+		serveTime uint64
+	}{
+		{"0.000929675", 929},
+		{"929675", 929},
+		{"2", 0},
+	}
+	for _, c := range cases {
+		line := `{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","method":"GET","uri":"/"},"duration":` + c.duration + `,"size":10900,"status":200}`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.ServeTime != c.serveTime {
+			t.Errorf("duration %s: want (%v), get (%v)", c.duration, c.serveTime, logitem.ServeTime)
+		}
+	}
+}
+
+func TestDefaultStatus(t *testing.T) {
+	logfmt := `%h [%d:%t %^] %b %T`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+	line := `10.0.0.1 [11/Jun/2023:11:23:45 +0000] 1024 0.5`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Status != -1 {
+		t.Errorf("want (%v), get (%v)", -1, logitem.Status)
+	}
+
+	conf.DefaultStatus = 200
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "10.0.0.1",
+		Dt:        time.Date(2023, 6, 11, 11, 23, 45, 0, locationUTC),
+		Status:    200,
+		RespSize:  1024,
+		ServeTime: 500000,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+
+	// a fixed-width status is still a status
+	conf, err = goaccessfmt.SetupConfig(`%h %{3}s%b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.DefaultStatus = 200
+	logitem, err = goaccessfmt.ParseLine(conf, "1.2.3.4 404568")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Status != 404 || logitem.RespSize != 568 {
+		t.Errorf("want (%v, %v), get (%v, %v)", 404, 568, logitem.Status, logitem.RespSize)
+	}
+}
+
+func TestRequestIDExtension(t *testing.T) {
+	logfmt := `%h %^[%d:%t %^] "%r" %s %b "%i"`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568 "f3b2a1c4-9d8e-4f6a-b7c2-1e0d9a8b7c6d"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		Dt:        time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:       "/index.html",
+		Method:    "GET",
+		Protocol:  "HTTP/1.1",
+		Status:    200,
+		RespSize:  568,
+		RequestID: "f3b2a1c4-9d8e-4f6a-b7c2-1e0d9a8b7c6d",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestRecoverQuoting(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("combined")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+	conf.RecoverQuoting = true
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568 "-" "Mozilla/5.0 "Broken" Agent"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "114.5.1.4",
+		Dt:       time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:      "/index.html",
+		Method:   "GET",
+		Protocol: "HTTP/1.1",
+		Status:   200,
+		RespSize: 568,
+		Ref:      "-",
+		Agent:    `Mozilla/5.0 "Broken" Agent`,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+	if !logitem.RecoveredQuoting {
+		t.Error("want RecoveredQuoting set")
+	}
+
+	line = `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568 "-" "curl/8.0"`
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Agent != "curl/8.0" || logitem.RecoveredQuoting {
+		t.Errorf("want unrecovered agent (curl/8.0), get (%v, %v)", logitem.Agent, logitem.RecoveredQuoting)
+	}
+}
+
+func TestShortDateToken(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/23:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`
+	_, err = goaccessfmt.ParseLine(conf, line)
+	if err == nil || !strings.Contains(err.Error(), "shorter than the date format") {
+		t.Errorf("want date length error, get (%v)", err)
+	}
+}
+
+func TestAdjacentDateTime(t *testing.T) {
+	logfmt := `%h [%d%t] "%r" %s %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, "%Y%m%d", "%H%M%S", locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `114.5.1.4 [20230611112345] "GET /index.html HTTP/1.1" 200 568`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "114.5.1.4",
+		Dt:       time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:      "/index.html",
+		Method:   "GET",
+		Protocol: "HTTP/1.1",
+		Status:   200,
+		RespSize: 568,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestDuplicateSpecifierWarning(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h %^[%d:%t %^] "%r" %s %b %s`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := conf.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "'%s'") {
+		t.Errorf("want one warning about '%%s', get (%v)", warnings)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Warnings()) != 0 {
+		t.Errorf("want no warning, get (%v)", conf.Warnings())
+	}
+}
+
+func TestHostFallback(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("caddy")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+	conf.HostFallbackKey = "request.headers.X-Real-Ip[0]"
+	conf.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	cases := []struct {
+		clientIP string
+		host     string
+	}{
+		{"10.1.2.3", "203.0.113.7"},
+		{"", "203.0.113.7"},
+		{"198.51.100.1", "198.51.100.1"},
+	}
+	for _, c := range cases {
+		line := `{"ts":1646861401.5241024,"request":{"client_ip":"` + c.clientIP + `","method":"GET","uri":"/","headers":{"X-Real-Ip":["203.0.113.7"]}},"status":200}`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Host != c.host {
+			t.Errorf("client_ip %q: want (%v), get (%v)", c.clientIP, c.host, logitem.Host)
+		}
+	}
+}
+
+func TestTimingGroupExtension(t *testing.T) {
+	logfmt := `%h:%^ [%d:%t.%^] %^ %^/%^ %w %s %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "10.0.1.2",
+		Dt:        time.Date(2009, 2, 6, 12, 14, 14, 0, locationUTC),
+		Status:    200,
+		RespSize:  2750,
+		ServeTime: 109000,
+		Timings:   []uint64{10, 0, 30, 69, 109},
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func BenchmarkParseLineCombined(b *testing.B) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("combined")
+	if err != nil {
+		b.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.0.0 Safari/537.36"`
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := goaccessfmt.ParseLine(conf, line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseLineIntoCombined(b *testing.B) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("combined")
+	if err != nil {
+		b.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.0.0 Safari/537.36"`
+	var logitem goaccessfmt.GLogItem
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := goaccessfmt.ParseLineInto(conf, line, &logitem); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseLineInto(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "curl/8.0.1"`
+	want, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logitem := goaccessfmt.GLogItem{VHost: "stale", CacheStatus: "HIT"}
+	if err := goaccessfmt.ParseLineInto(conf, line, &logitem); err != nil {
+		t.Fatal(err)
+	}
+	if !logitem.Equal(*want) {
+		t.Errorf("want (%v), get (%v)", *want, logitem)
+	}
+	if err := goaccessfmt.ParseLineInto(conf, "", &logitem); err == nil {
+		t.Error("want error for an empty line")
+	}
+}
+
+func TestJSONHostArray(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("caddy")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","method":"GET","host":["example.com","example.org"],"uri":"/"},"status":200}`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.VHost != "example.com" {
+		t.Errorf("want (%v), get (%v)", "example.com", logitem.VHost)
+	}
+}
+
+func TestStatusReason(t *testing.T) {
+	logfmt := `%h [%d:%t %^] "%r" "%s" %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		status string
+		code   int
+		reason string
+	}{
+		{"200 OK", 200, "OK"},
+		{"404 Not Found", 404, "Not Found"},
+		{"301", 301, ""},
+	}
+	for _, c := range cases {
+		line := `10.0.0.1 [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" "` + c.status + `" 0`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Status != c.code || logitem.StatusReason != c.reason {
+			t.Errorf("want (%v, %v), get (%v, %v)", c.code, c.reason, logitem.Status, logitem.StatusReason)
+		}
+	}
+}
+
+func TestCloudStorage(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("cloudstorage")
+	if err != nil {
+		t.Error(err)
+	}
+	if logfmt != goaccessfmt.Logs.CloudStorage {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.Logs.CloudStorage, logfmt)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `"1588464534617000","203.0.113.5","1","","GET","/example-bucket/path/obj.txt","200","0","1234","45000","storage.googleapis.com","https://example.com/","Mozilla/5.0 (X11; Linux x86_64)","AAANNmEkfRI8pdJYtFm0QjTBY8G7TRQeLw","GET_Object","example-bucket","path/obj.txt"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "203.0.113.5",
+		Dt:        time.Date(2020, 5, 3, 0, 8, 54, 617000000, locationUTC),
+		Method:    "GET",
+		Req:       "/example-bucket/path/obj.txt",
+		Status:    200,
+		RespSize:  1234,
+		ServeTime: 45000,
+		Ref:       "https://example.com/",
+		Agent:     "Mozilla/5.0 (X11; Linux x86_64)",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestRecoverMalformedReq(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("combined")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /index.ht`
+	if _, err := goaccessfmt.ParseLine(conf, line); err == nil {
+		t.Error("want error for truncated request, get nil")
+	}
+
+	conf.RecoverMalformedReq = true
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:   "114.5.1.4",
+		Dt:     time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		Req:    "GET /index.ht",
+		Status: -1,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+	if !logitem.Malformed {
+		t.Error("want Malformed set")
+	}
+
+	logitem, err = goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "-" 408 0 "-" "-"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "-" || logitem.Malformed {
+		t.Errorf("want (-, false), get (%v, %v)", logitem.Req, logitem.Malformed)
+	}
+}
+
+func TestTwoDigitYear(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, "%d/%b/%y", goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		date string
+		year int
+	}{
+		{"11/Jun/23", 2023},
+		{"11/Jun/68", 2068},
+		{"11/Jun/69", 1969},
+		{"11/Jun/99", 1999},
+	}
+	for _, c := range cases {
+		line := `114.5.1.4 - - [` + c.date + `:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		want := time.Date(c.year, 6, 11, 11, 23, 45, 0, locationP8)
+		if !logitem.Dt.Equal(want) {
+			t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+		}
+	}
+}
+
+func TestSplitHostList(t *testing.T) {
+	logfmt := `"%h" %^[%d:%t %^] "%r" %s %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.SplitHostList = true
+
+	lines := []string{
+		`"1.2.3.4, 5.6.7.8" - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`,
+		`"1.2.3.4" - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`,
+		`"unknown, 1.2.3.4" - - [11/Jun/2023:11:23:45 +0800] "GET /index.html HTTP/1.1" 200 568`,
+	}
+	for _, line := range lines {
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Host != "1.2.3.4" {
+			t.Errorf("want (%v), get (%v)", "1.2.3.4", logitem.Host)
+		}
+	}
+}
+
+func TestNginxUpstream(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("nginx_upstream")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /api/items?page=2 HTTP/1.1" 200 1024 "-" "curl/8.0.1" 0.123 0.120`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Error(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		Dt:        time.Date(2023, time.Month(6), 11, 11, 23, 45, 0, locationP8),
+		Req:       "/api/items?page=2",
+		Status:    200,
+		RespSize:  1024,
+		Ref:       "-",
+		Agent:     "curl/8.0.1",
+		Method:    "GET",
+		Protocol:  "HTTP/1.1",
+		ServeTime: 123000,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestTimeOffset(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%d:%t] "%r" %s %b`, goaccessfmt.Dates.Apache, "%H:%M:%S %z", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		offset string
+		want   int
+	}{
+		{"+0000", 0},
+		{"-0000", 0},
+		{"+08", 8 * 3600},
+	}
+	conf.UseLogTimezone = true
+	for _, c := range cases {
+		line := `114.5.1.4 [11/Jun/2023:11:23:45 ` + c.offset + `] "GET / HTTP/1.1" 200 568`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		_, offset := logitem.Dt.Zone()
+		if offset != c.want {
+			t.Errorf("want (%v), get (%v)", c.want, offset)
+		}
+		if logitem.Dt.Hour() != 11 || logitem.Dt.Minute() != 23 || logitem.Dt.Second() != 45 {
+			t.Errorf("want (11:23:45), get (%v)", logitem.Dt)
+		}
+		if c.want == 0 && logitem.Dt.Location() != time.UTC {
+			t.Errorf("want (UTC), get (%v)", logitem.Dt.Location())
+		}
+	}
+}
+
+func TestHostWithPort(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"1.2.3.4:8080", "1.2.3.4"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"2001:db8::1", "2001:db8::1"},
+	}
+	for _, c := range cases {
+		line := c.host + ` - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Host != c.want {
+			t.Errorf("want (%v), get (%v)", c.want, logitem.Host)
+		}
+		if logitem.Status != 200 || logitem.Agent != "curl/8.0.1" {
+			t.Errorf("want (200, curl/8.0.1), get (%v, %v)", logitem.Status, logitem.Agent)
+		}
+	}
+
+	// the port is left to the following specifier
+	conf, err = goaccessfmt.SetupConfig(goaccessfmt.Logs.AWSELB, goaccessfmt.Dates.W3C, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `https 2015-05-13T23:39:43.945958Z my-loadbalancer [2001:db8::1]:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - - arn "-" "-"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Host != "2001:db8::1" || logitem.Status != 200 {
+		t.Errorf("want (2001:db8::1, 200), get (%v, %v)", logitem.Host, logitem.Status)
+	}
+}
+
+func TestRequestWithSpaces(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		request string
+		req     string
+		method  string
+	}{
+		{"GET /a b HTTP/1.1", "/a b", "GET"},
+		{"GET /my file  name.txt HTTP/1.1 ", "/my file  name.txt", "GET"},
+		{"POST /a b/c d HTTP/2.0", "/a b/c d", "POST"},
+		{"GETX /a b HTTP/1.1", "GETX /a b HTTP/1.1", ""},
+		{"GET HTTP/1.1", "-", ""},
+		{"GET  HTTP/1.1", "-", ""},
+	}
+	for _, c := range cases {
+		line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "` + c.request + `" 200 568 "-" "curl/8.0.1"`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Req != c.req || logitem.Method != c.method {
+			t.Errorf("want (%v, %v), get (%v, %v)", c.req, c.method, logitem.Req, logitem.Method)
+		}
+	}
+}
+
+func TestStatusSlashSize(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%x.%^ %L %h %^ %s/%b %m %U`, "%s", "%s", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `1286536309.450 1 192.168.0.68 TCP_MISS 200/568 GET http://example.com/a`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Status != 200 || logitem.RespSize != 568 {
+		t.Errorf("want (200, 568), get (%v, %v)", logitem.Status, logitem.RespSize)
+	}
+	if logitem.Method != "GET" || logitem.Req != "http://example.com/a" {
+		t.Errorf("want (GET, http://example.com/a), get (%v, %v)", logitem.Method, logitem.Req)
+	}
+}
+
+func TestSetupConfigMulti(t *testing.T) {
+	combined, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined.FormatName = "combined"
+	caddy, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Caddy, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caddy.FormatName = "caddy"
+	conf := goaccessfmt.SetupConfigMulti(combined, caddy)
+
+	lineA := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /a HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, lineA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.FormatName != "combined" || logitem.Req != "/a" {
+		t.Errorf("want (combined, /a), get (%v, %v)", logitem.FormatName, logitem.Req)
+	}
+
+	lineB := `{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/b"},"duration":0.000929675,"size":10900,"status":200}`
+	logitem, err = goaccessfmt.ParseLine(conf, lineB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.FormatName != "caddy" || logitem.Req != "/b" {
+		t.Errorf("want (caddy, /b), get (%v, %v)", logitem.FormatName, logitem.Req)
+	}
+
+	if _, err := goaccessfmt.ParseLine(conf, "garbage"); err == nil {
+		t.Error("want error, get nil")
+	}
+}
+
+func TestSubSecondTimestamp(t *testing.T) {
+	cases := []struct {
+		format string
+		ts     string
+		nanos  int
+	}{
+		{"%f", "1646861401524102", 524102000},
+		{"%*", "1646861401524", 524000000},
+		{"%s", "1646861401.5241024", 524102400},
+	}
+	for _, c := range cases {
+		conf, err := goaccessfmt.SetupConfig(`%x %h`, c.format, c.format, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, c.ts+" 127.0.0.1")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Dt.Unix() != 1646861401 || logitem.Dt.Nanosecond() != c.nanos {
+			t.Errorf("want (%v, %v), get (%v, %v)", 1646861401, c.nanos, logitem.Dt.Unix(), logitem.Dt.Nanosecond())
+		}
+	}
+}
+
+func TestZoneName(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%d:%t] "%r" %s %b`, goaccessfmt.Dates.Apache, "%H:%M:%S %Z", locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ZoneOffsets = map[string]int{"PST": -8 * 3600, "UTC": 0}
+
+	cases := []struct {
+		zone string
+		want int
+	}{
+		{"PST", -8 * 3600},
+		{"UTC", 0},
+		// unknown, falls back to conf.Timezone
+		{"XYZ", 8 * 3600},
+	}
+	for _, c := range cases {
+		line := `114.5.1.4 [11/Jun/2023:11:23:45 ` + c.zone + `] "GET / HTTP/1.1" 200 568`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if _, offset := logitem.Dt.Zone(); offset != c.want {
+			t.Errorf("want (%v), get (%v)", c.want, offset)
+		}
+		if logitem.Dt.Hour() != 11 {
+			t.Errorf("want (11), get (%v)", logitem.Dt.Hour())
+		}
+	}
+}
+
+func TestTimestampTimezone(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	for _, format := range []string{"%s", "%f"} {
+		conf, err := goaccessfmt.SetupConfig(`%x %h`, format, format, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts := "1646861401"
+		if format == "%f" {
+			ts += "000000"
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, ts+" 127.0.0.1")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if _, offset := logitem.Dt.Zone(); offset != -5*3600 {
+			t.Errorf("want (%v), get (%v)", -5*3600, offset)
+		}
+		if logitem.Dt.Unix() != 1646861401 {
+			t.Errorf("want (%v), get (%v)", 1646861401, logitem.Dt.Unix())
+		}
+	}
+}
+
+func TestCloudFront(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("cloudfront")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, result := range []string{"Hit", "RefreshHit", "Miss", "Error"} {
+		line := "2019-12-04\t21:02:31\tLAX1-C3\t392\t192.0.2.100\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tcurl/8.0.1\t-\t-\t" + result + "\tSOX4xwn4XV6Q4rgb7XiVGOHms_BGlTAC4KyHmureZmBNrjGdRLiNIQ==\td111111abcdef8.cloudfront.net\thttps\t23\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\t" + result + "\tHTTP/2.0\t-\t-\t11040\t0.001\t" + result + "\ttext/html\t78\t-\t-"
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		expectedLogitem := goaccessfmt.GLogItem{
+			Host:        "192.0.2.100",
+			Dt:          time.Date(2019, 12, 4, 21, 2, 31, 0, locationUTC),
+			VHost:       "d111111abcdef8.cloudfront.net",
+			Method:      "GET",
+			Req:         "/index.html",
+			Qstr:        "-",
+			Protocol:    "HTTP/2",
+			Status:      200,
+			RespSize:    392,
+			ServeTime:   1000,
+			Ref:         "-",
+			Agent:       "curl/8.0.1",
+			CacheStatus: result,
+			TLSType:     "TLSv1.2",
+			TLSCypher:   "ECDHE-RSA-AES128-GCM-SHA256",
+		}
+		if !logitem.Equal(expectedLogitem) {
+			t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+		}
+	}
+}
+
+func TestTokenTransform(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.VCombined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.TokenTransform = func(spec byte, raw []byte) []byte {
+		switch spec {
+		case 'h', 'v':
+			return bytes.ToUpper(raw)
+		case 'r':
+			return bytes.TrimPrefix(raw, []byte("/app"))
+		}
+		return raw
+	}
+
+	line := `example.com:443 2001:db8::abcd - - [11/Jun/2023:11:23:45 +0800] "GET /app/a%20b HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Host != "2001:DB8::ABCD" || logitem.VHost != "EXAMPLE.COM" {
+		t.Errorf("want (2001:DB8::ABCD, EXAMPLE.COM), get (%v, %v)", logitem.Host, logitem.VHost)
+	}
+	if logitem.Req != "/a b" || logitem.Agent != "curl/8.0.1" {
+		t.Errorf("want (/a b, curl/8.0.1), get (%v, %v)", logitem.Req, logitem.Agent)
+	}
+}
+
+func TestJSONExponentTimestamp(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`{"ts": "%x", "request": {"client_ip": "%h"}}`, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ts   string
+		want time.Time
+	}{
+		{"1.6785513e9", time.Date(2023, 3, 11, 16, 15, 0, 0, locationUTC)},
+		{"1.67855130125E+9", time.Date(2023, 3, 11, 16, 15, 1, 250000000, locationUTC)},
+		{"1678551301.25", time.Date(2023, 3, 11, 16, 15, 1, 250000000, locationUTC)},
+	}
+	for _, c := range cases {
+		line := `{"ts":` + c.ts + `,"request":{"client_ip":"127.0.0.1"}}`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !logitem.Dt.Equal(c.want) {
+			t.Errorf("want (%v), get (%v)", c.want, logitem.Dt)
+		}
+	}
+}
+
+func TestStatusSign(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" +200 568`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Status != 200 {
+		t.Errorf("want (%v), get (%v)", 200, logitem.Status)
+	}
+
+	for _, status := range []string{"GET", "0xC8", "-200"} {
+		line = `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" ` + status + ` 568`
+		_, err = goaccessfmt.ParseLine(conf, line)
+		want := "token '" + status + "' doesn't match specifier '%s'"
+		if err == nil || err.Error() != want {
+			t.Errorf("want (%v), get (%v)", want, err)
+		}
+	}
+}
+
+func TestGeoLocationExtension(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h %g [%d:%t %^] "%r" %s %b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `114.5.1.4 CN [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:        "114.5.1.4",
+		GeoLocation: "CN",
+		Dt:          time.Date(2023, time.Month(6), 11, 11, 23, 45, 0, locationP8),
+		Req:         "/",
+		Status:      200,
+		RespSize:    568,
+		Method:      "GET",
+		Protocol:    "HTTP/1.1",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestLenientParsing(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" OK 568 "-" "curl/8.0.1"`
+
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err == nil || logitem != nil {
+		t.Errorf("want (nil, error), get (%v, %v)", logitem, err)
+	}
+
+	conf.LenientParsing = true
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err == nil {
+		t.Error("want error, get nil")
+	}
+	if logitem == nil {
+		t.Fatal("want partial item, get nil")
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:     "114.5.1.4",
+		Dt:       time.Date(2023, time.Month(6), 11, 11, 23, 45, 0, locationP8),
+		Req:      "/",
+		Status:   -1,
+		RespSize: 568,
+		Ref:      "-",
+		Agent:    "curl/8.0.1",
+		Method:   "GET",
+		Protocol: "HTTP/1.1",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestCaddyExtended(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("caddy_extended")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	line := `{"level":"info","ts":1646861401.5241024,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"127.0.0.1","remote_port":"41342","client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/search?q=caddy&page=2","headers":{"User-Agent":["curl/7.82.0"],"X-Request-Id":["f2b3c4d5"]}},"duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Content-Type":["text/html; charset=utf-8"]}}`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "127.0.0.1",
+		Dt:        time.Date(2022, 3, 9, 21, 30, 1, 524102400, locationUTC),
+		VHost:     "localhost",
+		Method:    "GET",
+		Req:       "/search",
+		Qstr:      "q=caddy&page=2",
+		Protocol:  "HTTP/2",
+		Status:    200,
+		RespSize:  10900,
+		Agent:     "curl/7.82.0",
+		ServeTime: 929,
+		MimeType:  "text/html; charset=utf-8",
+		RequestID: "f2b3c4d5",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+
+	// no query string
+	line = `{"ts":1646861401.5241024,"request":{"client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/"},"duration":0.000929675,"size":10900,"status":200}`
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "/" || logitem.Qstr != "" {
+		t.Errorf("want (/, ), get (%v, %v)", logitem.Req, logitem.Qstr)
+	}
+}
+
+func TestOptionalQuery(t *testing.T) {
+	cases := []struct {
+		logfmt, line   string
+		req, qstr, ref string
+	}{
+		{`%h "%U?%q" %s "%R"`, `114.5.1.4 "/a" 200 "/r"`, "/a", "", "/r"},
+		{`%h "%U?%q" %s "%R"`, `114.5.1.4 "/a" 200 "/r?y"`, "/a", "", "/r?y"},
+		{`%h "%U?%q" %s "%R"`, `114.5.1.4 "/a?x=1" 200 "/r?y"`, "/a", "x=1", "/r?y"},
+		{`%h %U?%q %s %R`, `114.5.1.4 /a 200 /r?y`, "/a", "", "/r?y"},
+	}
+	for _, c := range cases {
+		conf, err := goaccessfmt.SetupConfig(c.logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, c.line)
+		if err != nil {
+			t.Errorf("%s: %v", c.line, err)
+			continue
+		}
+		if logitem.Req != c.req || logitem.Qstr != c.qstr || logitem.Ref != c.ref || logitem.Status != 200 {
+			t.Errorf("want (%v, %v, %v), get (%v, %v, %v)", c.req, c.qstr, c.ref, logitem.Req, logitem.Qstr, logitem.Ref)
+		}
+	}
+}
+
+func TestSwappedDateTime(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%d %t] "%r" %s %b`, "%d/%b/%Y", goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [11/Jun/2023 11:23:45] "GET / HTTP/1.1" 200 568`); err != nil {
+		t.Error(err)
+	}
+
+	_, err = goaccessfmt.ParseLine(conf, `114.5.1.4 [11:23:45 11/Jun/2023] "GET / HTTP/1.1" 200 568`)
+	if err == nil || !strings.Contains(err.Error(), "looks like a time") {
+		t.Errorf("want error about a time token, get (%v)", err)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(`%h [%t %^] "%r" %s %b`, "%d/%b/%Y", goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = goaccessfmt.ParseLine(conf, `114.5.1.4 [11/Jun/2023 11:23:45] "GET / HTTP/1.1" 200 568`)
+	if err == nil || !strings.Contains(err.Error(), "looks like a date") {
+		t.Errorf("want error about a date token, get (%v)", err)
+	}
+}
+
+func TestJSONFieldMap(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Caddy, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"ts":                            "%x",
+		"request.client_ip":             "%h",
+		"request.proto":                 "%H",
+		"request.method":                "%m",
+		"request.host":                  "%v",
+		"request.uri":                   "%U",
+		"request.headers.User-Agent[0]": "%u",
+		"request.headers.Referer[0]":    "%R",
+		"request.tls.cipher_suite":      "%k",
+		"request.tls.proto":             "%K",
+		"duration":                      "%T",
+		"size":                          "%b",
+		"status":                        "%s",
+		"resp_headers.Content-Type[0]":  "%M",
+	}
+	fields := conf.JSONFieldMap()
+	if !maps.Equal(fields, want) {
+		t.Errorf("want (%v), get (%v)", want, fields)
+	}
+	// the returned map is a copy
+	fields["status"] = "%b"
+	if conf.JSONFieldMap()["status"] != "%s" {
+		t.Error("JSONFieldMap returned the internal map")
+	}
+
+	conf, err = goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := conf.JSONFieldMap(); fields != nil {
+		t.Errorf("want nil for a non-JSON format, get (%v)", fields)
+	}
+}
+
+func TestGLogItemString(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "curl/8.0.1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := logitem.String()
+	if strings.Contains(s, "\n") {
+		t.Errorf("want a single line, get (%v)", s)
+	}
+	for _, want := range []string{`Host="114.5.1.4"`, "Status=429", "Dt=2023-06-11T11:23:45+08:00"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("want (%v) in (%v)", want, s)
+		}
+	}
+	if strings.Index(s, "Host=") > strings.Index(s, "Status=") {
+		t.Errorf("unexpected field order in (%v)", s)
+	}
+}
+
+func TestJSONEscapedFields(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568 "https:\/\/example.com\/" "Mozilla\/5.0 \x22quoted\x22 caf\u00e9"`
+
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Mozilla\/5.0 \x22quoted\x22 caf\u00e9`; logitem.Agent != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Agent)
+	}
+
+	conf.JSONEscapedFields = true
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Mozilla/5.0 "quoted" café`; logitem.Agent != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Agent)
+	}
+	if want := "https://example.com/"; logitem.Ref != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Ref)
+	}
+}
+
+func TestIgnoreSpecifier(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h %^ %^ %^[%d:%t %^] "%r" %s %b %^`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Warnings()) != 0 {
+		t.Errorf("want no warning, get (%v)", conf.Warnings())
+	}
+	conf.Strict = true
+	logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 - alice x [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568 trailing data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Host != "114.5.1.4" || logitem.Req != "/" || logitem.Status != 200 || logitem.RespSize != 568 {
+		t.Errorf("unexpected item (%v)", logitem)
+	}
+	if logitem.Userid != "" {
+		t.Errorf("want %%^ not to capture, get userid (%v)", logitem.Userid)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(`%h %Z [%d:%t %^] "%r" %s %b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := conf.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "'%Z'") {
+		t.Errorf("want a warning about %%Z, get (%v)", warnings)
+	}
+}
+
+func TestSpecError(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" abc 568 "-" "curl/8.0.1"`)
+	var specErr *goaccessfmt.SpecError
+	if !errors.As(err, &specErr) {
+		t.Fatalf("want a SpecError, get (%v)", err)
+	}
+	if specErr.Spec != 's' || specErr.Code != goaccessfmt.ERR_SPEC_TOKN_INV || specErr.Token != "abc" {
+		t.Errorf("unexpected SpecError (%+v)", *specErr)
+	}
+	if want := "token 'abc' doesn't match specifier '%s'"; err.Error() != want {
+		t.Errorf("want (%v), get (%v)", want, err)
+	}
+}
+
+func TestEndTimestamp(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%x] [%X] "%r" %s %b`, "%Y-%m-%dT%H:%M:%S%z", "%Y-%m-%dT%H:%M:%S%z", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [2023-06-11T11:23:45+0000] [2023-06-11T11:23:47+0000] "GET / HTTP/1.1" 200 568`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 11, 23, 45, 0, time.UTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+	if want := time.Date(2023, 6, 11, 11, 23, 47, 0, time.UTC); !logitem.DtEnd.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.DtEnd)
+	}
+	if want := 2 * time.Second; logitem.Duration() != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Duration())
+	}
+
+	logitem.DtEnd = time.Time{}
+	if logitem.Duration() != 0 {
+		t.Errorf("want 0 without end timestamp, get (%v)", logitem.Duration())
+	}
+}
+
+func TestEndTimestampZone(t *testing.T) {
+	cases := []struct {
+		timefmt, start, end string
+		zones               map[string]int
+		want                time.Time
+	}{
+		{"%Y-%m-%dT%H:%M:%S%z", "2023-06-11T11:23:45+0800", "2023-06-11T11:23:47+0800", nil, time.Date(2023, 6, 11, 3, 23, 45, 0, time.UTC)},
+		{"%Y-%m-%d %H:%M:%S %Z", "2023-06-11 11:23:45 PST", "2023-06-11 11:23:47 PST", map[string]int{"PST": -8 * 3600}, time.Date(2023, 6, 11, 19, 23, 45, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		conf, err := goaccessfmt.SetupConfig(`%h [%x] [%X] %s`, c.timefmt, c.timefmt, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.ZoneOffsets = c.zones
+		logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [`+c.start+`] [`+c.end+`] 200`)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !logitem.Dt.Equal(c.want) {
+			t.Errorf("want (%v), get (%v)", c.want, logitem.Dt)
+		}
+		if want := 2 * time.Second; logitem.Duration() != want {
+			t.Errorf("want (%v), get (%v)", want, logitem.Duration())
+		}
+	}
+}
+
+func TestJSONParseErrors(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Caddy, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = goaccessfmt.ParseLine(conf, `{"ts": 1686482625.1, "request": {"client_ip": "114.5.1.4"`)
+	if !errors.Is(err, goaccessfmt.ErrJSONDecode) {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.ErrJSONDecode, err)
+	}
+	var parseErr *goaccessfmt.ParseError
+	if errors.As(err, &parseErr) {
+		t.Errorf("want no ParseError for a broken line, get (%v)", parseErr)
+	}
+
+	_, err = goaccessfmt.ParseLine(conf, `{"ts": 1686482625.1, "request": {"client_ip": "114.5.1.4", "method": "GET", "uri": "/"}, "status": "abc"}`)
+	if errors.Is(err, goaccessfmt.ErrJSONDecode) {
+		t.Errorf("want a field error, get (%v)", err)
+	}
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("want a ParseError, get (%v)", err)
+	}
+	if parseErr.Key != "status" {
+		t.Errorf("want key (%v), get (%v)", "status", parseErr.Key)
+	}
+	var specErr *goaccessfmt.SpecError
+	if !errors.As(err, &specErr) || specErr.Spec != 's' {
+		t.Errorf("want a SpecError for %%s, get (%v)", err)
+	}
+}
+
+func TestPaddedDay(t *testing.T) {
+	tests := []struct {
+		dateFormat string
+		date       string
+		want       time.Time
+	}{
+		{"%b %e", "Dec 2", time.Date(1900, 12, 2, 10, 11, 12, 0, time.UTC)},
+		{"%b %e", "Dec  2", time.Date(1900, 12, 2, 10, 11, 12, 0, time.UTC)},
+		{"%b %e", "Dec 12", time.Date(1900, 12, 12, 10, 11, 12, 0, time.UTC)},
+		{"%b %e %Y", "Dec  2 2023", time.Date(2023, 12, 2, 10, 11, 12, 0, time.UTC)},
+		{"%b %e %Y", "Dec 12 2023", time.Date(2023, 12, 12, 10, 11, 12, 0, time.UTC)},
+		{"%Y %b %e", "2023 Dec  2", time.Date(2023, 12, 2, 10, 11, 12, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		conf, err := goaccessfmt.SetupConfig("%d %t %h", test.dateFormat, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, test.date+" 10:11:12 114.5.1.4")
+		if err != nil {
+			t.Errorf("%q: %v", test.date, err)
+			continue
+		}
+		if !logitem.Dt.Equal(test.want) || logitem.Host != "114.5.1.4" {
+			t.Errorf("%q: want (%v, 114.5.1.4), get (%v, %v)", test.date, test.want, logitem.Dt, logitem.Host)
+		}
+	}
+}
+
+func TestJSONNestedSize(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`{"client": "%h", "metrics": {"bytes_sent": "%b"}}`, "%d", "%t", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := conf.JSONFieldMap(); fields["metrics.bytes_sent"] != "%b" {
+		t.Errorf("want metrics.bytes_sent mapped to %%b, get (%v)", fields)
+	}
+	tests := []struct {
+		size string
+		want uint64
+	}{
+		{"1234", 1234},
+		{"1.234e3", 1234},
+		{"1234.0", 1234},
+		{"12345678901234567", 12345678901234567},
+		{`"1234"`, 1234},
+	}
+	for _, test := range tests {
+		logitem, err := goaccessfmt.ParseLine(conf, `{"client": "114.5.1.4", "metrics": {"bytes_sent": `+test.size+`}}`)
+		if err != nil {
+			t.Errorf("%s: %v", test.size, err)
+			continue
+		}
+		if logitem.RespSize != test.want {
+			t.Errorf("%s: want (%v), get (%v)", test.size, test.want, logitem.RespSize)
+		}
+	}
+}
+
+func TestServeTimeUnitSuffix(t *testing.T) {
+	tests := []struct {
+		token string
+		want  uint64
+	}{
+		{"1.5s", 1500000},
+		{"250ms", 250000},
+		{"800us", 800},
+		{"1200000ns", 1200},
+	}
+	for _, spec := range []string{"%T", "%D", "%L"} {
+		conf, err := goaccessfmt.SetupConfig(`%h [%d:%t %^] "%r" %s %b `+spec, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568 `+test.token)
+			if err != nil {
+				t.Errorf("%s %s: %v", spec, test.token, err)
+				continue
+			}
+			if logitem.ServeTime != test.want {
+				t.Errorf("%s %s: want (%v), get (%v)", spec, test.token, test.want, logitem.ServeTime)
+			}
+		}
+	}
+}
+
+func TestExtraMethods(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "QUERY /search HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Method != "" || logitem.Req != "QUERY /search HTTP/1.1" {
+		t.Errorf("want the whole request line without method, get (%v, %v)", logitem.Method, logitem.Req)
+	}
+
+	conf.ExtraMethods = []string{"query"}
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Method != "QUERY" || logitem.Req != "/search" || logitem.Protocol != "HTTP/1.1" {
+		t.Errorf("want (QUERY, /search, HTTP/1.1), get (%v, %v, %v)", logitem.Method, logitem.Req, logitem.Protocol)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(`%h %m %U %s`, "%d", "%t", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ExtraMethods = []string{"QUERY"}
+	logitem, err = goaccessfmt.ParseLine(conf, `114.5.1.4 query /search 200`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Method != "QUERY" {
+		t.Errorf("want (QUERY), get (%v)", logitem.Method)
+	}
+
+	// extra methods starting with a built-in one
+	conf.ExtraMethods = []string{"GETALL"}
+	logitem, err = goaccessfmt.ParseLine(conf, `114.5.1.4 GETALL /search 200`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Method != "GETALL" {
+		t.Errorf("want (GETALL), get (%v)", logitem.Method)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ExtraMethods = []string{"UPDATEREDIRECTREF"}
+	logitem, err = goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "UPDATEREDIRECTREF /ref HTTP/1.1" 200 568 "-" "curl/8.0.1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Method != "UPDATEREDIRECTREF" || logitem.Req != "/ref" {
+		t.Errorf("want (UPDATEREDIRECTREF, /ref), get (%v, %v)", logitem.Method, logitem.Req)
+	}
+}
+
+func TestProtocolAliases(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.ExtraProtocols = []string{"spdy/3.1"}
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{"h2", "HTTP/2"},
+		{"h2c", "HTTP/2"},
+		{"h3", "HTTP/3"},
+		{"HTTP/2.0", "HTTP/2"},
+		{"SPDY/3.1", "SPDY/3.1"},
+	}
+	for _, test := range tests {
+		logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /index.html `+test.protocol+`" 200 568`)
+		if err != nil {
+			t.Errorf("%s: %v", test.protocol, err)
+			continue
+		}
+		if logitem.Protocol != test.want || logitem.Req != "/index.html" {
+			t.Errorf("%s: want (%v, /index.html), get (%v, %v)", test.protocol, test.want, logitem.Protocol, logitem.Req)
+		}
+	}
+
+	conf, err = goaccessfmt.SetupConfig(`%h %H %U`, "%d", "%t", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 h3 /index.html`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Protocol != "HTTP/3" {
+		t.Errorf("want (HTTP/3), get (%v)", logitem.Protocol)
+	}
+	if _, err := goaccessfmt.ParseLine(conf, `114.5.1.4 SPDY/3.1 /index.html`); err == nil {
+		t.Error("want error for a protocol not in ExtraProtocols")
+	}
+}
+
+func TestDecodeMode(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.5 - - [04/Apr/2024:09:02:13 +0800] "GET /search/a+b%20c?q=x+y HTTP/1.1" 200 163 "-" "-"`
+
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/search/a b c?q=x y"; logitem.Req != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Req)
+	}
+
+	conf.DecodeMode = goaccessfmt.RawURLDecode
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/search/a+b c?q=x+y"; logitem.Req != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Req)
+	}
+
+	logitem, err = goaccessfmt.ParseLine(conf, `114.5.1.5 - - [04/Apr/2024:09:02:13 +0800] "\x16\x03\x01\xC0+\xC0/\xC0" 400 163 "-" "-"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `\x16\x03\x01\xC0+\xC0/\xC0`; logitem.Req != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Req)
+	}
+}
+
+func TestEscapedQuotes(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568 "https://example.com/?q=\\" "Mozilla/5.0 \"quoted\" agent"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Mozilla/5.0 \"quoted\" agent`; logitem.Agent != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Agent)
+	}
+	if want := `https://example.com/?q=\\`; logitem.Ref != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Ref)
+	}
+
+	conf.JSONEscapedFields = true
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Mozilla/5.0 "quoted" agent`; logitem.Agent != want {
+		t.Errorf("want (%v), get (%v)", want, logitem.Agent)
+	}
+}
+
+func TestClientPort(t *testing.T) {
+	tests := []struct {
+		format string
+		line   string
+		host   string
+		port   int
+	}{
+		{`%h %^[%d:%t %^] "%r" %s %b`, `114.5.1.4:51234 - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568`, "114.5.1.4", 51234},
+		{`%h %^[%d:%t %^] "%r" %s %b`, `[2001:db8::1]:443 - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568`, "2001:db8::1", 443},
+		{`%h %^[%d:%t %^] "%r" %s %b`, `2001:db8::1 - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568`, "2001:db8::1", 0},
+		{`%h %^[%d:%t %^] "%r" %s %b`, `114.5.1.4 - [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568`, "114.5.1.4", 0},
+		{`{"client": "%h"}`, `{"client": "[2001:db8::1]:8443"}`, "2001:db8::1", 8443},
+	}
+	for _, test := range tests {
+		conf, err := goaccessfmt.SetupConfig(test.format, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, test.line)
+		if err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		if logitem.Host != test.host || logitem.Port != test.port {
+			t.Errorf("%s: want (%v, %v), get (%v, %v)", test.line, test.host, test.port, logitem.Host, logitem.Port)
+		}
+	}
+}
+
+func TestEnvoy(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("envoy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"start_time":"2023-06-11T03:23:45.123Z","method":"GET","path":"/api/v1/items","protocol":"HTTP/1.1","response_code":200,"response_flags":"-","bytes_received":0,"bytes_sent":568,"duration":12,"upstream_service_time":"10","x_forwarded_for":null,"user_agent":"curl/8.0.1","request_id":"3e4c1a9a-8f7b-4d9e-9d2a-5b0c6f1e2a3b","authority":"example.com","upstream_host":"10.0.0.5:8080","downstream_remote_address":"114.5.1.4:51234"}`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		Port:      51234,
+		Dt:        time.Date(2023, 6, 11, 3, 23, 45, 123000000, time.UTC),
+		Method:    "GET",
+		Req:       "/api/v1/items",
+		Protocol:  "HTTP/1.1",
+		Status:    200,
+		RespSize:  568,
+		ServeTime: 12000,
+		Agent:     "curl/8.0.1",
+		RequestID: "3e4c1a9a-8f7b-4d9e-9d2a-5b0c6f1e2a3b",
+		VHost:     "example.com",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestEnvoyOffset(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("envoy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, `{"start_time":"2023-06-11T11:23:45.123+0800","method":"GET","path":"/","response_code":200,"downstream_remote_address":"114.5.1.4:51234"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 3, 23, 45, 123000000, time.UTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		`114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568`,
+		`not a log line`,
+		``,
+		`114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "GET /b HTTP/1.1" abc 1`,
+		`# comment`,
+		`114.5.1.6 - - [11/Jun/2023:11:23:47 +0000] "GET /c HTTP/1.1" 404 2`,
+	}
+	logitems, errs := goaccessfmt.ParseLines(conf, lines)
+	var reqs []string
+	for _, logitem := range logitems {
+		reqs = append(reqs, logitem.Req)
+	}
+	if want := []string{"/a", "/c"}; !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+	var failed []int
+	for _, err := range errs {
+		var lineErr *goaccessfmt.LineError
+		if !errors.As(err, &lineErr) {
+			t.Fatalf("want a LineError, get (%v)", err)
+		}
+		failed = append(failed, lineErr.Line)
+	}
+	if want := []int{1, 3}; !slices.Equal(failed, want) {
+		t.Errorf("want errors for lines (%v), get (%v)", want, failed)
+	}
+	var specErr *goaccessfmt.SpecError
+	if len(errs) == 2 && (!errors.As(errs[1], &specErr) || specErr.Spec != 's') {
+		t.Errorf("want a SpecError for %%s, get (%v)", errs[1])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`{"clientip": "%h", "status": "%s", "headers": {"ua": "%u"}}`, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"client_ip": "114.5.1.4", "status": 200, "headers": {"ua": "curl/8.0"}}`
+	err = conf.Validate(line)
+	if err == nil || !strings.Contains(err.Error(), "clientip") || strings.Contains(err.Error(), "status") {
+		t.Errorf("want an error reporting only clientip, get (%v)", err)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(`{"client_ip": "%h", "status": "%s", "headers": {"ua": "%u"}}`, goaccessfmt.Dates.Sec, goaccessfmt.Times.Sec, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Validate(line); err != nil {
+		t.Error(err)
+	}
+	if err := conf.Validate("not json"); !errors.Is(err, goaccessfmt.ErrJSONDecode) {
+		t.Errorf("want ErrJSONDecode, get (%v)", err)
+	}
+
+	conf, err = goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Validate("anything"); err != nil {
+		t.Errorf("want nil for a non-JSON format, get (%v)", err)
+	}
+}
+
+func TestUpstreamStatus(t *testing.T) {
+	logfmt := `%h [%d:%t %^] "%r" "%s" %b`
+	conf, err := goaccessfmt.SetupConfig(logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		status   string
+		code     int
+		upstream int
+	}{
+		{"502 200", 502, 200},
+		{"502/200", 502, 200},
+		{"200", 200, 0},
+		// no upstream
+		{"502 -", 502, 0},
+		{"502/-", 502, 0},
+	}
+	for _, c := range cases {
+		line := `10.0.0.1 [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" "` + c.status + `" 0`
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.Status != c.code || logitem.UpstreamStatus != c.upstream || logitem.StatusReason != "" {
+			t.Errorf("want (%v, %v), get (%v, %v, %q)", c.code, c.upstream, logitem.Status, logitem.UpstreamStatus, logitem.StatusReason)
+		}
+	}
+
+	line := `10.0.0.1 [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" "502/abc" 0`
+	if _, err := goaccessfmt.ParseLine(conf, line); err == nil {
+		t.Error("want error for an invalid upstream status")
+	}
+}
+
+func TestVarnish(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("varnish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cacheStatus := range []string{"hit", "miss"} {
+		line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET http://example.com/index.html HTTP/1.1" 200 568 "-" "curl/8.0.1" ` + cacheStatus
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedLogitem := goaccessfmt.GLogItem{
+			Host:        "114.5.1.4",
+			Dt:          time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+			Method:      "GET",
+			Req:         "http://example.com/index.html",
+			Protocol:    "HTTP/1.1",
+			Status:      200,
+			RespSize:    568,
+			Ref:         "-",
+			Agent:       "curl/8.0.1",
+			CacheStatus: cacheStatus,
+		}
+		if !logitem.Equal(expectedLogitem) {
+			t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+		}
+	}
+}
+
+func TestCacheStatusRaw(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig("[%h] %C", goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.CacheStatuses = goaccessfmt.CloudFrontCacheStatuses
+
+	cases := []struct {
+		token       string
+		cacheStatus string
+		raw         string
+	}{
+		{"Hit", "Hit", ""},
+		{"RefreshHit", "RefreshHit", ""},
+		{"Miss", "Miss", ""},
+		{"DYNAMIC", "DYNAMIC", ""},
+		{"none", "none", ""},
+		{"-", "", ""},
+		{"PARTIAL_HIT", "", "PARTIAL_HIT"},
+	}
+	for _, c := range cases {
+		logitem, err := goaccessfmt.ParseLine(conf, "[114.5.1.4] "+c.token)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.CacheStatus != c.cacheStatus || logitem.CacheStatusRaw != c.raw {
+			t.Errorf("%s: want (%q, %q), get (%q, %q)", c.token, c.cacheStatus, c.raw, logitem.CacheStatus, logitem.CacheStatusRaw)
+		}
+	}
+}
+
+func TestPresetNames(t *testing.T) {
+	names := goaccessfmt.PresetNames()
+	if len(names) == 0 || names[0] != "combined" {
+		t.Fatalf("want names starting with combined, get (%v)", names)
+	}
+	if !slices.Contains(names, "caddy_content_length") || slices.Contains(names, "caddycontentlength") {
+		t.Errorf("want caddy_content_length, get (%v)", names)
+	}
+	for _, name := range names {
+		logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if _, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	// the returned slice is a copy
+	names[0] = "bogus"
+	if goaccessfmt.PresetNames()[0] != "combined" {
+		t.Error("PresetNames returned the internal slice")
+	}
+	if _, _, _, err := goaccessfmt.GetFmtFromPreset("bogus"); err == nil {
+		t.Error("want error for an unknown preset")
+	}
+}
+
+func TestSquid(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("squid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "Jun 11 03:23:45 proxy squid[1234]: 1686453825.123    120 114.5.1.4 TCP_MISS/200 568 GET http://example.com/index.html - HIER_DIRECT/93.184.216.34 text/html"
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		VHost:     "proxy",
+		Dt:        time.Date(2023, 6, 11, 3, 23, 45, 0, time.UTC),
+		Method:    "GET",
+		Req:       "http://example.com/index.html",
+		Status:    200,
+		RespSize:  568,
+		ServeTime: 120000,
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestTimeFraction(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig("[%h] %d %t", goaccessfmt.Dates.W3C, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		time  string
+		nanos int
+	}{
+		{"10:11:12.345", 345000000},
+		{"10:11:12.123456789123", 123456789},
+		{"10:11:12", 0},
+	}
+	for _, c := range cases {
+		logitem, err := goaccessfmt.ParseLine(conf, "[114.5.1.4] 2023-06-11 "+c.time)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if want := time.Date(2023, 6, 11, 10, 11, 12, c.nanos, locationUTC); !logitem.Dt.Equal(want) {
+			t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+		}
+	}
+
+	// an explicit fraction in the time format
+	conf, err = goaccessfmt.SetupConfig("[%h] %d %t", goaccessfmt.Dates.W3C, "%H:%M:%S.%f", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logitem, err := goaccessfmt.ParseLine(conf, "[114.5.1.4] 2023-06-11 10:11:12.345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 10, 11, 12, 345000000, locationUTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+}
+
+func TestAWSALBFull(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("awsalb_full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `https 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.086 0.048 0.037 200 200 0 57 "GET https://www.example.com:443/index.html HTTP/1.1" "curl/7.46.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337281-1d84f3d73c47ec4e58577259" "www.example.com" "arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 2018-07-02T22:22:48.364000Z "authenticate,forward" "-" "-" "10.0.0.1:80" "200" "-" "-" TID_1234abcd`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "192.168.131.39",
+		VHost:     "www.example.com",
+		Dt:        time.Date(2018, 7, 2, 22, 23, 0, 0, locationUTC),
+		Method:    "GET",
+		Req:       "https://www.example.com:443/index.html",
+		Protocol:  "HTTP/1.1",
+		Status:    200,
+		RespSize:  57,
+		ServeTime: 48000,
+		Agent:     "curl/7.46.0",
+		TLSCypher: "ECDHE-RSA-AES128-GCM-SHA256",
+		TLSType:   "TLSv1.2",
+		TraceID:   "Root=1-58337281-1d84f3d73c47ec4e58577259",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestTraefikJSON(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("traefik_json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"ClientAddr":"114.5.1.4:51234","ClientHost":"114.5.1.4","ClientPort":"51234","ClientUsername":"-","DownstreamContentSize":568,"DownstreamStatus":200,"Duration":1500000,"OriginContentSize":568,"OriginDuration":1200000,"OriginStatus":200,"Overhead":300000,"RequestAddr":"example.com","RequestContentSize":0,"RequestCount":42,"RequestHost":"example.com","RequestMethod":"GET","RequestPath":"/api/items?page=2","RequestPort":"-","RequestProtocol":"HTTP/2.0","RequestScheme":"https","RetryAttempts":0,"RouterName":"api@docker","ServiceName":"api@docker","StartLocal":"2023-06-11T11:23:45.123456789+08:00","StartUTC":"2023-06-11T03:23:45.123456789Z","entryPointName":"websecure","level":"info","msg":"","request_User-Agent":"curl/8.0.1","time":"2023-06-11T11:23:45+08:00"}`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		VHost:     "example.com",
+		Dt:        time.Date(2023, 6, 11, 3, 23, 45, 123456789, time.UTC),
+		Method:    "GET",
+		Req:       "/api/items",
+		Qstr:      "page=2",
+		Protocol:  "HTTP/2",
+		Status:    200,
+		RespSize:  568,
+		ServeTime: 1500,
+		Agent:     "curl/8.0.1",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+}
+
+func TestServeTimeUnits(t *testing.T) {
+	// ServeTime is in microseconds whatever the unit of the specifier
+	tests := []struct {
+		spec  string
+		token string
+		want  uint64
+	}{
+		{"%T", "1.5", 1500000},
+		{"%L", "1500", 1500000},
+		{"%D", "1500", 1500},
+		{"%n", "1500000", 1500},
+	}
+	for _, test := range tests {
+		conf, err := goaccessfmt.SetupConfig(`%h [%d:%t %^] "%r" %s %b `+test.spec, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [11/Jun/2023:11:23:45 +0000] "GET / HTTP/1.1" 200 568 `+test.token)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.spec, test.token, err)
+			continue
+		}
+		if logitem.ServeTime != test.want {
+			t.Errorf("%s %s: want (%v), get (%v)", test.spec, test.token, test.want, logitem.ServeTime)
+		}
+	}
+}
+
+func TestReqSize(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%d:%t %^] "%r" %s %B %b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		sizes    string
+		reqSize  uint64
+		respSize uint64
+	}{
+		{"1024 568", 1024, 568},
+		{"- 568", 0, 568},
+	}
+	for _, c := range cases {
+		logitem, err := goaccessfmt.ParseLine(conf, `114.5.1.4 [11/Jun/2023:11:23:45 +0000] "POST /upload HTTP/1.1" 200 `+c.sizes)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if logitem.ReqSize != c.reqSize || logitem.RespSize != c.respSize {
+			t.Errorf("%s: want (%v, %v), get (%v, %v)", c.sizes, c.reqSize, c.respSize, logitem.ReqSize, logitem.RespSize)
+		}
+		if s := logitem.String(); !strings.Contains(s, fmt.Sprintf("ReqSize=%d ", c.reqSize)) {
+			t.Errorf("ReqSize missing from (%v)", s)
+		}
+	}
+}
+
+func TestUseLogTimezone(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h %^[%d:%t %z] "%r" %s %b`, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568`
+
+	// off: the offset is ignored as in goaccess
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 11, 23, 45, 0, locationUTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+
+	conf.UseLogTimezone = true
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 3, 23, 45, 0, time.UTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+	if _, offset := logitem.Dt.Zone(); offset != 8*60*60 {
+		t.Errorf("want offset (%v), get (%v)", 8*60*60, offset)
+	}
+
+	if _, err := goaccessfmt.ParseLine(conf, `114.5.1.4 - - [11/Jun/2023:11:23:45 bogus] "GET / HTTP/1.1" 200 568`); err == nil {
+		t.Error("want error for an invalid offset")
+	}
+}
+
+func TestUseLogTimezoneTimeFormat(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(`%h [%d:%t] "%r" %s %b`, goaccessfmt.Dates.Apache, "%H:%M:%S %z", locationUTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568`
+
+	// off: the offset is ignored as in goaccess
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 11, 23, 45, 0, time.UTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+
+	conf.UseLogTimezone = true
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 11, 3, 23, 45, 0, time.UTC); !logitem.Dt.Equal(want) {
+		t.Errorf("want (%v), get (%v)", want, logitem.Dt)
+	}
+}
+
+func TestDisableURLDecode(t *testing.T) {
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /files/a%2Fb.txt?next=%2Fhome HTTP/1.1" 200 568 "-" "curl%2F8.0.1"`
+	cases := []struct {
+		logfmt     string
+		req, raw   string
+		qstr, rawq string
+	}{
+		{goaccessfmt.Logs.Combined, "/files/a/b.txt?next=/home", "/files/a%2Fb.txt?next=%2Fhome", "", ""},
+		{`%h %^[%d:%t %^] "%m %U?%q %H" %s %b "%R" "%u"`, "/files/a/b.txt", "/files/a%2Fb.txt", "next=/home", "next=%2Fhome"},
+	}
+	for _, c := range cases {
+		conf, err := goaccessfmt.SetupConfig(c.logfmt, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationUTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logitem, err := goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if logitem.Req != c.req || logitem.Qstr != c.qstr || logitem.Agent != "curl/8.0.1" {
+			t.Errorf("want decoded fields, get (%v, %v, %v)", logitem.Req, logitem.Qstr, logitem.Agent)
+		}
+
+		conf.DisableURLDecode = true
+		logitem, err = goaccessfmt.ParseLine(conf, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if logitem.Req != c.raw || logitem.Qstr != c.rawq || logitem.Agent != "curl%2F8.0.1" {
+			t.Errorf("want fields as logged, get (%v, %v, %v)", logitem.Req, logitem.Qstr, logitem.Agent)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package goaccessfmt
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// glogItemJSON is the JSON representation of GLogItem. Every field is
+// always emitted, so that the schema does not depend on the log format.
+type glogItemJSON struct {
+	Host             string   `json:"host"`
+	Port             int      `json:"port"`
+	VHost            string   `json:"vhost"`
+	Userid           string   `json:"userid"`
+	Dt               string   `json:"dt"`
+	DtEnd            string   `json:"dt_end"`
+	Method           string   `json:"method"`
+	Req              string   `json:"req"`
+	Qstr             string   `json:"qstr"`
+	Protocol         string   `json:"protocol"`
+	Status           int      `json:"status"`
+	StatusReason     string   `json:"status_reason"`
+	UpstreamStatus   int      `json:"upstream_status"`
+	RespSize         uint64   `json:"resp_size"`
+	ReqSize          uint64   `json:"req_size"`
+	ServeTime        uint64   `json:"serve_time"`
+	Ref              string   `json:"ref"`
+	Agent            string   `json:"agent"`
+	CacheStatus      string   `json:"cache_status"`
+	CacheStatusRaw   string   `json:"cache_status_raw"`
+	MimeType         string   `json:"mime_type"`
+	TLSType          string   `json:"tls_type"`
+	TLSCypher        string   `json:"tls_cypher"`
+	Server           string   `json:"server"`
+	RequestID        string   `json:"request_id"`
+	TraceID          string   `json:"trace_id"`
+	GeoLocation      string   `json:"geo_location"`
+	Level            string   `json:"level"`
+	Message          string   `json:"message"`
+	Timings          []uint64 `json:"timings"`
+	RawReq           string   `json:"raw_req"`
+	FormatName       string   `json:"format_name"`
+	RecoveredQuoting bool     `json:"recovered_quoting"`
+	Malformed        bool     `json:"malformed"`
+}
+
+// MarshalJSON encodes the item with snake_case keys, and Dt in RFC 3339
+// with sub-second digits if any. DtEnd is empty when not set.
+func (a GLogItem) MarshalJSON() ([]byte, error) {
+	timings := a.Timings
+	if timings == nil {
+		timings = []uint64{}
+	}
+	dtEnd := ""
+	if !a.DtEnd.IsZero() {
+		dtEnd = a.DtEnd.Format(time.RFC3339Nano)
+	}
+	return json.Marshal(glogItemJSON{
+		Host:             a.Host,
+		Port:             a.Port,
+		VHost:            a.VHost,
+		Userid:           a.Userid,
+		Dt:               a.Dt.Format(time.RFC3339Nano),
+		DtEnd:            dtEnd,
+		Method:           a.Method,
+		Req:              a.Req,
+		Qstr:             a.Qstr,
+		Protocol:         a.Protocol,
+		Status:           a.Status,
+		StatusReason:     a.StatusReason,
+		UpstreamStatus:   a.UpstreamStatus,
+		RespSize:         a.RespSize,
+		ReqSize:          a.ReqSize,
+		ServeTime:        a.ServeTime,
+		Ref:              a.Ref,
+		Agent:            a.Agent,
+		CacheStatus:      a.CacheStatus,
+		CacheStatusRaw:   a.CacheStatusRaw,
+		MimeType:         a.MimeType,
+		TLSType:          a.TLSType,
+		TLSCypher:        a.TLSCypher,
+		Server:           a.Server,
+		RequestID:        a.RequestID,
+		TraceID:          a.TraceID,
+		GeoLocation:      a.GeoLocation,
+		Level:            a.Level,
+		Message:          a.Message,
+		Timings:          timings,
+		RawReq:           a.RawReq,
+		FormatName:       a.FormatName,
+		RecoveredQuoting: a.RecoveredQuoting,
+		Malformed:        a.Malformed,
+	})
+}
+
+// UnmarshalJSON decodes an item encoded by MarshalJSON.
+func (a *GLogItem) UnmarshalJSON(data []byte) error {
+	var j glogItemJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var dt, dtEnd time.Time
+	if j.Dt != "" {
+		var err error
+		if dt, err = time.Parse(time.RFC3339Nano, j.Dt); err != nil {
+			return err
+		}
+	}
+	if j.DtEnd != "" {
+		var err error
+		if dtEnd, err = time.Parse(time.RFC3339Nano, j.DtEnd); err != nil {
+			return err
+		}
+	}
+	*a = GLogItem{
+		Agent:            j.Agent,
+		Host:             j.Host,
+		Port:             j.Port,
+		Method:           j.Method,
+		Protocol:         j.Protocol,
+		Qstr:             j.Qstr,
+		Ref:              j.Ref,
+		Req:              j.Req,
+		Status:           j.Status,
+		StatusReason:     j.StatusReason,
+		UpstreamStatus:   j.UpstreamStatus,
+		VHost:            j.VHost,
+		Userid:           j.Userid,
+		CacheStatus:      j.CacheStatus,
+		CacheStatusRaw:   j.CacheStatusRaw,
+		RespSize:         j.RespSize,
+		ReqSize:          j.ReqSize,
+		ServeTime:        j.ServeTime,
+		MimeType:         j.MimeType,
+		TLSType:          j.TLSType,
+		TLSCypher:        j.TLSCypher,
+		Server:           j.Server,
+		RequestID:        j.RequestID,
+		TraceID:          j.TraceID,
+		GeoLocation:      j.GeoLocation,
+		Level:            j.Level,
+		Message:          j.Message,
+		Timings:          j.Timings,
+		Dt:               dt,
+		DtEnd:            dtEnd,
+		FormatName:       j.FormatName,
+		RecoveredQuoting: j.RecoveredQuoting,
+		Malformed:        j.Malformed,
+		RawReq:           j.RawReq,
+	}
+	return nil
+}
+
+// ToMap returns the item as a flat map keyed like MarshalJSON, but with
+// native values: Status is an int, sizes and times are uint64, and Dt is a
+// time.Time.
+func (a GLogItem) ToMap() map[string]any {
+	return map[string]any{
+		"host":              a.Host,
+		"port":              a.Port,
+		"vhost":             a.VHost,
+		"userid":            a.Userid,
+		"dt":                a.Dt,
+		"dt_end":            a.DtEnd,
+		"method":            a.Method,
+		"req":               a.Req,
+		"qstr":              a.Qstr,
+		"protocol":          a.Protocol,
+		"status":            a.Status,
+		"status_reason":     a.StatusReason,
+		"upstream_status":   a.UpstreamStatus,
+		"resp_size":         a.RespSize,
+		"req_size":          a.ReqSize,
+		"serve_time":        a.ServeTime,
+		"ref":               a.Ref,
+		"agent":             a.Agent,
+		"cache_status":      a.CacheStatus,
+		"cache_status_raw":  a.CacheStatusRaw,
+		"mime_type":         a.MimeType,
+		"tls_type":          a.TLSType,
+		"tls_cypher":        a.TLSCypher,
+		"server":            a.Server,
+		"request_id":        a.RequestID,
+		"trace_id":          a.TraceID,
+		"geo_location":      a.GeoLocation,
+		"level":             a.Level,
+		"message":           a.Message,
+		"timings":           a.Timings,
+		"raw_req":           a.RawReq,
+		"format_name":       a.FormatName,
+		"recovered_quoting": a.RecoveredQuoting,
+		"malformed":         a.Malformed,
+	}
+}
@@ -0,0 +1,78 @@
+package goaccessfmt_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := `{"host":"114.5.1.4","port":0,"vhost":"","userid":"","dt":"2023-06-11T11:23:45+08:00","dt_end":"","method":"GET","req":"/example/path/file.img","qstr":"","protocol":"HTTP/1.1","status":429,"status_reason":"","upstream_status":0,"resp_size":568,"req_size":0,"serve_time":0,"ref":"-","agent":"curl/8.0.1","cache_status":"","cache_status_raw":"","mime_type":"","tls_type":"","tls_cypher":"","server":"","request_id":"","trace_id":"","geo_location":"","level":"","message":"","timings":[],"raw_req":"","format_name":"","recovered_quoting":false,"malformed":false}`
+	data, err := json.Marshal(logitem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != golden {
+		t.Errorf("want (%v), get (%v)", golden, string(data))
+	}
+
+	var decoded goaccessfmt.GLogItem
+	if err := json.Unmarshal([]byte(golden), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(*logitem) {
+		t.Errorf("want (%v), get (%v)", *logitem, decoded)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /example/path/file.img HTTP/1.1" 429 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := logitem.ToMap()
+	want := map[string]any{
+		"host":      "114.5.1.4",
+		"dt":        time.Date(2023, 6, 11, 11, 23, 45, 0, locationP8),
+		"method":    "GET",
+		"req":       "/example/path/file.img",
+		"protocol":  "HTTP/1.1",
+		"status":    429,
+		"resp_size": uint64(568),
+		"ref":       "-",
+		"agent":     "curl/8.0.1",
+		"malformed": false,
+	}
+	for k, v := range want {
+		if dt, ok := v.(time.Time); ok {
+			if got, ok := m[k].(time.Time); !ok || !got.Equal(dt) {
+				t.Errorf("%s: want (%v), get (%v)", k, v, m[k])
+			}
+			continue
+		}
+		if m[k] != v {
+			t.Errorf("%s: want (%#v), get (%#v)", k, v, m[k])
+		}
+	}
+	if _, ok := m["timings"]; !ok {
+		t.Error("missing timings key")
+	}
+}
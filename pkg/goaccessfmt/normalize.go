@@ -0,0 +1,31 @@
+package goaccessfmt
+
+import "strings"
+
+// ReqNormalization controls how GLogItem.NormalizedReq rewrites the request
+// path, e.g. for aggregating requests that differ only in spelling.
+type ReqNormalization struct {
+	Lowercase bool
+	// StripQuery drops the query string still attached to the request
+	// ("/a?b=c" becomes "/a").
+	StripQuery bool
+	// CollapseSlashes replaces runs of '/' with a single one.
+	CollapseSlashes bool
+}
+
+// NormalizedReq returns Req rewritten according to n.
+func (a GLogItem) NormalizedReq(n ReqNormalization) string {
+	req := a.Req
+	if n.StripQuery {
+		req, _, _ = strings.Cut(req, "?")
+	}
+	if n.CollapseSlashes {
+		for strings.Contains(req, "//") {
+			req = strings.ReplaceAll(req, "//", "/")
+		}
+	}
+	if n.Lowercase {
+		req = strings.ToLower(req)
+	}
+	return req
+}
@@ -0,0 +1,32 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestNormalizeReq(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.NormalizeReq = goaccessfmt.ReqNormalization{CollapseSlashes: true}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET //Static///img//a.png?v=1 HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "/Static/img/a.png?v=1" {
+		t.Errorf("want (%v), get (%v)", "/Static/img/a.png?v=1", logitem.Req)
+	}
+	if logitem.RawReq != "//Static///img//a.png?v=1" {
+		t.Errorf("want (%v), get (%v)", "//Static///img//a.png?v=1", logitem.RawReq)
+	}
+
+	norm := goaccessfmt.ReqNormalization{Lowercase: true, StripQuery: true, CollapseSlashes: true}
+	if req := logitem.NormalizedReq(norm); req != "/static/img/a.png" {
+		t.Errorf("want (%v), get (%v)", "/static/img/a.png", req)
+	}
+}
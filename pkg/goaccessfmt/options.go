@@ -0,0 +1,20 @@
+package goaccessfmt
+
+// WithDoubleDecode returns a copy of conf with DoubleDecodeEnabled set.
+func (conf Config) WithDoubleDecode(enabled bool) Config {
+	conf.DoubleDecodeEnabled = enabled
+	return conf
+}
+
+// WithDecodeURL returns a copy of conf with URL decoding enabled or
+// disabled (see DisableURLDecode).
+func (conf Config) WithDecodeURL(enabled bool) Config {
+	conf.DisableURLDecode = !enabled
+	return conf
+}
+
+// WithStrict returns a copy of conf with Strict set.
+func (conf Config) WithStrict(strict bool) Config {
+	conf.Strict = strict
+	return conf
+}
@@ -0,0 +1,113 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestConfigSetters(t *testing.T) {
+	base, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := base.WithDoubleDecode(true).WithDoubleDecode(false).WithDoubleDecode(true)
+	if !conf.DoubleDecodeEnabled {
+		t.Error("double decode is not enabled")
+	}
+	if base.DoubleDecodeEnabled {
+		t.Error("setters modified the original config")
+	}
+
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /a%2520b HTTP/1.1" 200 568`
+	logitem, err := goaccessfmt.ParseLine(base, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "/a%20b" {
+		t.Errorf("want (%v), get (%v)", "/a%20b", logitem.Req)
+	}
+	logitem, err = goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "/a b" {
+		t.Errorf("want (%v), get (%v)", "/a b", logitem.Req)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	base, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := base.WithStrict(true)
+	if !conf.Strict || base.Strict {
+		t.Errorf("want (true, false), get (%v, %v)", conf.Strict, base.Strict)
+	}
+
+	// a combined line has trailing fields the common format does not cover
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 568 "-" "curl/8.0.1"`
+	if _, err := goaccessfmt.ParseLine(base, line); err != nil {
+		t.Error(err)
+	}
+	if _, err := goaccessfmt.ParseLine(conf, line); err == nil {
+		t.Error("want error for trailing data in strict mode, get nil")
+	}
+
+	for _, size := range []string{"568", "-"} {
+		line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 ` + size
+		if _, err := goaccessfmt.ParseLine(conf, line); err != nil {
+			t.Errorf("%s: %v", size, err)
+		}
+	}
+	line = `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET / HTTP/1.1" 200 abc`
+	if _, err := goaccessfmt.ParseLine(base, line); err != nil {
+		t.Error(err)
+	}
+	if _, err := goaccessfmt.ParseLine(conf, line); err == nil {
+		t.Error("want error for an invalid size in strict mode, get nil")
+	}
+
+	quoted, err := goaccessfmt.SetupConfig(`[%h]`, "%d", "%t", locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := goaccessfmt.ParseLine(quoted, `[114.5.1.4] extra`); err != nil {
+		t.Error(err)
+	}
+	if _, err := goaccessfmt.ParseLine(quoted.WithStrict(true), `[114.5.1.4] extra`); err == nil {
+		t.Error("want error in strict mode, get nil")
+	}
+
+	// each JSON value is parsed with its own format
+	jsonConf, err := goaccessfmt.SetupConfig(`{"size": "%b"}`, "%d", "%t", locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := goaccessfmt.ParseLine(jsonConf.WithStrict(true), `{"size": "1k"}`); err == nil {
+		t.Error("want error for an invalid JSON size in strict mode, get nil")
+	}
+}
+
+func TestWithDecodeURL(t *testing.T) {
+	base, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, locationP8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := base.WithDecodeURL(false)
+	if !conf.DisableURLDecode || base.DisableURLDecode {
+		t.Errorf("want (true, false), get (%v, %v)", conf.DisableURLDecode, base.DisableURLDecode)
+	}
+	line := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0800] "GET /a%20b HTTP/1.1" 200 568`
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Req != "/a%20b" {
+		t.Errorf("want (%v), get (%v)", "/a%20b", logitem.Req)
+	}
+	if !conf.WithDecodeURL(true).WithDecodeURL(false).DisableURLDecode {
+		t.Error("chained setters do not keep the last value")
+	}
+}
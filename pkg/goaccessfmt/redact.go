@@ -0,0 +1,72 @@
+package goaccessfmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+)
+
+// RedactPolicy controls which fields GLogItem.RedactedWith masks.
+type RedactPolicy struct {
+	// MaskHost and MaskVHost mask IP addresses by zeroing the host part
+	// (last octet of IPv4, last 80 bits of IPv6) and replace other values
+	// with a short hash.
+	MaskHost  bool
+	MaskVHost bool
+	// MaxQstrLen, if positive, truncates Qstr to at most this many bytes.
+	MaxQstrLen int
+	DropUserid bool
+}
+
+// DefaultRedactPolicy is the policy used by GLogItem.Redacted.
+var DefaultRedactPolicy = RedactPolicy{
+	MaskHost:   true,
+	MaskVHost:  true,
+	MaxQstrLen: 8,
+	DropUserid: true,
+}
+
+// Redacted returns a copy of the item with personal data masked according
+// to DefaultRedactPolicy, for sharing sample logs safely.
+func (a GLogItem) Redacted() GLogItem {
+	return a.RedactedWith(DefaultRedactPolicy)
+}
+
+// RedactedWith returns a copy of the item with personal data masked
+// according to policy.
+func (a GLogItem) RedactedWith(policy RedactPolicy) GLogItem {
+	if policy.MaskHost {
+		a.Host = maskHost(a.Host)
+	}
+	if policy.MaskVHost {
+		a.VHost = maskHost(a.VHost)
+	}
+	if policy.MaxQstrLen > 0 && len(a.Qstr) > policy.MaxQstrLen {
+		a.Qstr = a.Qstr[:policy.MaxQstrLen]
+	}
+	if policy.DropUserid {
+		a.Userid = ""
+	}
+	return a
+}
+
+// maskHost zeroes the host part of an IP address, or hashes anything else.
+func maskHost(host string) string {
+	if host == "" {
+		return ""
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		sum := sha256.Sum256([]byte(host))
+		return hex.EncodeToString(sum[:4])
+	}
+	bits := 24
+	if !addr.Is4() {
+		bits = 48
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
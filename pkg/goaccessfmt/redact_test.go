@@ -0,0 +1,42 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestRedacted(t *testing.T) {
+	logitem := goaccessfmt.GLogItem{
+		Host:   "114.5.1.4",
+		VHost:  "example.com",
+		Req:    "/search",
+		Qstr:   "?q=secret-token",
+		Userid: "alice",
+		Status: 200,
+	}
+	redacted := logitem.Redacted()
+	if redacted.Host != "114.5.1.0" {
+		t.Errorf("want (%v), get (%v)", "114.5.1.0", redacted.Host)
+	}
+	if redacted.Qstr != "?q=secre" {
+		t.Errorf("want (%v), get (%v)", "?q=secre", redacted.Qstr)
+	}
+	if redacted.Userid != "" {
+		t.Errorf("want empty userid, get (%v)", redacted.Userid)
+	}
+	if redacted.VHost == "example.com" || redacted.VHost == "" {
+		t.Errorf("want hashed vhost, get (%v)", redacted.VHost)
+	}
+	if redacted.Req != logitem.Req || redacted.Status != logitem.Status {
+		t.Error("want other fields unchanged")
+	}
+	if logitem.Host != "114.5.1.4" {
+		t.Error("want original item unchanged")
+	}
+
+	redacted = logitem.RedactedWith(goaccessfmt.RedactPolicy{MaskHost: true})
+	if redacted.Host != "114.5.1.0" || redacted.Qstr != logitem.Qstr || redacted.Userid != logitem.Userid {
+		t.Errorf("want only host masked, get (%v)", redacted)
+	}
+}
@@ -0,0 +1,263 @@
+package goaccessfmt
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"sync"
+)
+
+// maxLineSize is the longest line the streaming helpers accept by default.
+const maxLineSize = 1024 * 1024
+
+// ErrByteBudgetExceeded is returned by a reader from NewBudgetReader once
+// more than its byte budget would be read, and by the streaming helpers
+// when the input exceeds StreamOptions.MaxTotalBytes.
+var ErrByteBudgetExceeded = errors.New("byte budget exceeded")
+
+type budgetReader struct {
+	r      io.Reader
+	remain int64
+}
+
+// NewBudgetReader returns a reader that reads from r but fails with
+// ErrByteBudgetExceeded instead of returning more than budget bytes in total.
+// Wrap decompressed input with it to guard against decompression bombs.
+func NewBudgetReader(r io.Reader, budget int64) io.Reader {
+	return &budgetReader{r: r, remain: budget}
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.remain <= 0 {
+		// only fail if there is something left to read
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrByteBudgetExceeded
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.r.Read(p)
+	b.remain -= int64(n)
+	return n, err
+}
+
+// StreamOptions tunes the streaming helpers.
+type StreamOptions struct {
+	// OnError, if not nil, is called with every line that fails to parse.
+	// Such lines are skipped.
+	OnError func(line string, err error)
+	// DedupeBy, if not nil, computes a key for every item. Items with the
+	// same key as the immediately preceding item are skipped, which
+	// collapses runs of repeated requests (e.g. health checks).
+	DedupeBy func(*GLogItem) string
+	// MaxLineSize, if positive, overrides the longest line accepted (1 MiB by
+	// default). Longer lines stop the stream with bufio.ErrTooLong.
+	MaxLineSize int
+	// MaxTotalBytes, if positive, limits the bytes read from the input in
+	// total, after decompression for ParseGzipReader. Longer input stops
+	// the stream with ErrByteBudgetExceeded.
+	MaxTotalBytes int64
+}
+
+// ParseReader parses each line read from r with conf and calls fn with the
+// item, or with the error if the line fails to parse (after opts.OnError).
+// As with ParseLine, the item is nil on error unless conf.LenientParsing.
+// Empty and comment lines are skipped, as are items dropped by
+// opts.DedupeBy. Parsing stops early when fn returns false.
+func ParseReader(conf Config, r io.Reader, opts StreamOptions, fn func(*GLogItem, error) bool) error {
+	if opts.MaxTotalBytes > 0 {
+		r = NewBudgetReader(r, opts.MaxTotalBytes)
+	}
+	scanner := bufio.NewScanner(r)
+	size := maxLineSize
+	if opts.MaxLineSize > 0 {
+		size = opts.MaxLineSize
+	}
+	scanner.Buffer(make([]byte, 0, min(64*1024, size)), size)
+	if conf.SkipIncompleteLastLine {
+		scanner.Split(scanCompleteLines)
+	}
+	lastKey := ""
+	hasLast := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !validLine(line) {
+			continue
+		}
+		logitem, err := ParseLine(conf, line)
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(line, err)
+			}
+			if !fn(logitem, err) {
+				return nil
+			}
+			continue
+		}
+		if opts.DedupeBy != nil {
+			key := opts.DedupeBy(logitem)
+			if hasLast && key == lastKey {
+				continue
+			}
+			lastKey, hasLast = key, true
+		}
+		if !fn(logitem, nil) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Parse returns an iterator over the lines read from r parsed with conf, as
+// in ParseReader with default options. The error reading r, if any, is
+// yielded last with a nil item.
+//
+//	for logitem, err := range goaccessfmt.Parse(conf, f) {
+//		...
+//	}
+func Parse(conf Config, r io.Reader) iter.Seq2[*GLogItem, error] {
+	return func(yield func(*GLogItem, error) bool) {
+		stopped := false
+		err := ParseReader(conf, r, StreamOptions{}, func(logitem *GLogItem, err error) bool {
+			stopped = !yield(logitem, err)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			yield(nil, err)
+		}
+	}
+}
+
+// ParseConcurrent parses the lines read from r with conf on workers
+// goroutines. Items are sent to the first channel, in no particular order,
+// and a *LineError for each line that fails to parse, or the error reading
+// r, to the second one. Empty and comment lines are skipped. Both channels
+// are closed at the end of r, and the caller must receive from both until
+// then, or cancel ctx to stop parsing early (a Read of r in progress is not
+// interrupted). conf, including TokenTransform, must not be modified
+// meanwhile.
+func ParseConcurrent(ctx context.Context, conf Config, r io.Reader, workers int) (<-chan *GLogItem, <-chan error) {
+	workers = max(workers, 1)
+	type numberedLine struct {
+		n    int
+		line string
+	}
+	lines := make(chan numberedLine, workers)
+	items := make(chan *GLogItem, workers)
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range lines {
+				if !validLine(l.line) {
+					continue
+				}
+				logitem, err := ParseLine(conf, l.line)
+				if err != nil {
+					select {
+					case errs <- &LineError{Line: l.n, Err: err}:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				select {
+				case items <- logitem:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		if conf.SkipIncompleteLastLine {
+			scanner.Split(scanCompleteLines)
+		}
+	scan:
+		for n := 0; scanner.Scan(); n++ {
+			select {
+			case lines <- numberedLine{n: n, line: scanner.Text()}:
+			case <-ctx.Done():
+				break scan
+			}
+		}
+		close(lines)
+		wg.Wait()
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+		}
+		close(items)
+		close(errs)
+	}()
+	return items, errs
+}
+
+// ParseGzipReader is ParseReader for gzip-compressed input, e.g. a rotated
+// log file. Concatenated gzip members are read one after another, and an
+// empty input has no lines. A truncated input is an error. Set
+// opts.MaxTotalBytes to guard against decompression bombs.
+func ParseGzipReader(conf Config, r io.Reader, opts StreamOptions, fn func(*GLogItem, error) bool) error {
+	zr, err := gzip.NewReader(r)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return ParseReader(conf, zr, opts, fn)
+}
+
+// scanCompleteLines is bufio.ScanLines, but drops the final line if it has no
+// trailing newline.
+func scanCompleteLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if atEOF && advance == len(data) && len(data) > 0 && data[len(data)-1] != '\n' {
+		return len(data), nil, nil
+	}
+	return advance, token, err
+}
+
+// streamLines is ParseReader for callers that skip failed lines and may
+// stop with an error.
+func streamLines(r io.Reader, conf Config, opts StreamOptions, fn func(*GLogItem) error) error {
+	var fnErr error
+	err := ParseReader(conf, r, opts, func(logitem *GLogItem, err error) bool {
+		if err != nil {
+			return true
+		}
+		fnErr = fn(logitem)
+		return fnErr == nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// WriteNDJSON parses each line read from r with conf and writes the items to
+// w as newline-delimited JSON.
+func WriteNDJSON(w io.Writer, r io.Reader, conf Config, opts StreamOptions) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return streamLines(r, conf, opts, func(logitem *GLogItem) error {
+		return encoder.Encode(logitem)
+	})
+}
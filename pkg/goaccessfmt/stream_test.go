@@ -0,0 +1,407 @@
+package goaccessfmt_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestBudgetReader(t *testing.T) {
+	input := strings.Repeat("a", 100)
+
+	data, err := io.ReadAll(goaccessfmt.NewBudgetReader(strings.NewReader(input), 100))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(data) != 100 {
+		t.Errorf("want 100 bytes, get %d", len(data))
+	}
+
+	data, err = io.ReadAll(goaccessfmt.NewBudgetReader(strings.NewReader(input), 64))
+	if !errors.Is(err, goaccessfmt.ErrByteBudgetExceeded) {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.ErrByteBudgetExceeded, err)
+	}
+	if len(data) != 64 {
+		t.Errorf("want 64 bytes, get %d", len(data))
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, time.UTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	input := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568
+invalid
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "POST /b HTTP/1.1" 404 12
+`
+	var out bytes.Buffer
+	var failed []string
+	err = goaccessfmt.WriteNDJSON(&out, strings.NewReader(input), conf, goaccessfmt.StreamOptions{
+		OnError: func(line string, err error) {
+			failed = append(failed, line)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != "invalid" {
+		t.Errorf("want (%v) failed, get (%v)", []string{"invalid"}, failed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, get %d", len(lines))
+	}
+	var logitem goaccessfmt.GLogItem
+	if err := json.Unmarshal([]byte(lines[1]), &logitem); err != nil {
+		t.Fatal(err)
+	}
+	if logitem.Host != "114.5.1.5" || logitem.Status != 404 || logitem.Req != "/b" {
+		t.Errorf("unexpected item (%v)", logitem)
+	}
+}
+
+func TestStreamDedupe(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, time.UTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	input := `10.0.0.1 - - [11/Jun/2023:11:23:45 +0000] "GET /healthz HTTP/1.1" 200 2
+10.0.0.1 - - [11/Jun/2023:11:23:50 +0000] "GET /healthz HTTP/1.1" 200 2
+10.0.0.1 - - [11/Jun/2023:11:23:55 +0000] "GET /healthz HTTP/1.1" 200 2
+114.5.1.4 - - [11/Jun/2023:11:23:56 +0000] "GET /index.html HTTP/1.1" 200 568
+10.0.0.1 - - [11/Jun/2023:11:24:00 +0000] "GET /healthz HTTP/1.1" 200 2
+`
+	var out bytes.Buffer
+	err = goaccessfmt.WriteNDJSON(&out, strings.NewReader(input), conf, goaccessfmt.StreamOptions{
+		DedupeBy: func(logitem *goaccessfmt.GLogItem) string {
+			return logitem.Host + " " + logitem.Req
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reqs []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var logitem goaccessfmt.GLogItem
+		if err := json.Unmarshal([]byte(line), &logitem); err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, logitem.Req)
+	}
+	want := []string{"/healthz", "/index.html", "/healthz"}
+	if !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, time.UTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	input := `# comment
+
+114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568
+invalid
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "POST /b HTTP/1.1" 404 12
+114.5.1.6 - - [11/Jun/2023:11:23:47 +0000] "GET /c HTTP/1.1" 200 1
+`
+	var hosts []string
+	errs := 0
+	err = goaccessfmt.ParseReader(conf, strings.NewReader(input), goaccessfmt.StreamOptions{}, func(logitem *goaccessfmt.GLogItem, err error) bool {
+		if err != nil {
+			errs++
+			return true
+		}
+		hosts = append(hosts, logitem.Host)
+		return len(hosts) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs != 1 {
+		t.Errorf("want (%v) errors, get (%v)", 1, errs)
+	}
+	want := []string{"114.5.1.4", "114.5.1.5"}
+	if !slices.Equal(hosts, want) {
+		t.Errorf("want (%v), get (%v)", want, hosts)
+	}
+
+	long := `{"host": "` + strings.Repeat("a", 200) + `"}`
+	conf, err = goaccessfmt.SetupConfig(`{"host": "%h"}`, "%d", "%t", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = goaccessfmt.ParseReader(conf, strings.NewReader(long), goaccessfmt.StreamOptions{MaxLineSize: 100}, func(*goaccessfmt.GLogItem, error) bool {
+		return true
+	})
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("want (%v), get (%v)", bufio.ErrTooLong, err)
+	}
+
+	err = goaccessfmt.ParseReader(conf, strings.NewReader(long+"\n"+long), goaccessfmt.StreamOptions{MaxTotalBytes: 300}, func(*goaccessfmt.GLogItem, error) bool {
+		return true
+	})
+	if !errors.Is(err, goaccessfmt.ErrByteBudgetExceeded) {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.ErrByteBudgetExceeded, err)
+	}
+}
+
+func TestSkipIncompleteLastLine(t *testing.T) {
+	logfmt, datefmt, timefmt, err := goaccessfmt.GetFmtFromPreset("common")
+	if err != nil {
+		t.Error(err)
+	}
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, time.UTC)
+	if err != nil {
+		t.Error(err)
+	}
+
+	input := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "GET /b HTTP/1.1" 200 1`
+	parse := func(conf goaccessfmt.Config, input string) []string {
+		var reqs []string
+		err := goaccessfmt.ParseReader(conf, strings.NewReader(input), goaccessfmt.StreamOptions{}, func(logitem *goaccessfmt.GLogItem, err error) bool {
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			reqs = append(reqs, logitem.Req)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reqs
+	}
+
+	if want, reqs := []string{"/a", "/b"}, parse(conf, input); !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+	conf.SkipIncompleteLastLine = true
+	if want, reqs := []string{"/a"}, parse(conf, input); !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+	if want, reqs := []string{"/a", "/b"}, parse(conf, input+"\n"); !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+}
+
+func TestParseGzipReader(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Combined, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568 "-" "curl/8.0.1"
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "GET /b HTTP/1.1" 200 1 "-" "curl/8.0.1"
+114.5.1.6 - - [11/Jun/2023:11:23:47 +0000] "GET /c HTTP/1.1" 404 2 "-" "curl/8.0.1"
+`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(r io.Reader) ([]string, error) {
+		var reqs []string
+		err := goaccessfmt.ParseGzipReader(conf, r, goaccessfmt.StreamOptions{}, func(logitem *goaccessfmt.GLogItem, err error) bool {
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			reqs = append(reqs, logitem.Req)
+			return true
+		})
+		return reqs, err
+	}
+
+	reqs, err := parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/a", "/b", "/c"}; !slices.Equal(reqs, want) {
+		t.Errorf("want (%v), get (%v)", want, reqs)
+	}
+
+	if reqs, err := parse(strings.NewReader("")); err != nil || len(reqs) != 0 {
+		t.Errorf("want no item and no error for empty input, get (%v, %v)", reqs, err)
+	}
+	if _, err := parse(bytes.NewReader(buf.Bytes()[:buf.Len()-4])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("want (%v), get (%v)", io.ErrUnexpectedEOF, err)
+	}
+
+	// a gzip bomb: 64 MiB of empty lines in a few dozen KiB
+	var bomb bytes.Buffer
+	zw = gzip.NewWriter(&bomb)
+	chunk := bytes.Repeat([]byte("\n"), 1024*1024)
+	for range 64 {
+		if _, err := zw.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	opts := goaccessfmt.StreamOptions{MaxTotalBytes: 1024 * 1024}
+	err = goaccessfmt.ParseGzipReader(conf, &bomb, opts, func(*goaccessfmt.GLogItem, error) bool {
+		return true
+	})
+	if !errors.Is(err, goaccessfmt.ErrByteBudgetExceeded) {
+		t.Errorf("want (%v), get (%v)", goaccessfmt.ErrByteBudgetExceeded, err)
+	}
+	err = goaccessfmt.ParseGzipReader(conf, bytes.NewReader(buf.Bytes()), opts, func(*goaccessfmt.GLogItem, error) bool {
+		return true
+	})
+	if err != nil {
+		t.Errorf("want no error within the budget, get (%v)", err)
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input strings.Builder
+	var want []string
+	for i := range 200 {
+		if i%50 == 7 {
+			input.WriteString("invalid\n")
+			continue
+		}
+		req := "/" + strconv.Itoa(i)
+		want = append(want, req)
+		input.WriteString(`114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET ` + req + ` HTTP/1.1" 200 568` + "\n")
+	}
+	slices.Sort(want)
+
+	for _, workers := range []int{1, 4, 16} {
+		items, errs := goaccessfmt.ParseConcurrent(context.Background(), conf, strings.NewReader(input.String()), workers)
+		var reqs []string
+		var failed []int
+		for items != nil || errs != nil {
+			select {
+			case logitem, ok := <-items:
+				if !ok {
+					items = nil
+					continue
+				}
+				reqs = append(reqs, logitem.Req)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				var lineErr *goaccessfmt.LineError
+				if !errors.As(err, &lineErr) {
+					t.Fatalf("want a LineError, get (%v)", err)
+				}
+				failed = append(failed, lineErr.Line)
+			}
+		}
+		slices.Sort(reqs)
+		if !slices.Equal(reqs, want) {
+			t.Errorf("%d workers: want %d items, get %d", workers, len(want), len(reqs))
+		}
+		slices.Sort(failed)
+		if wantFailed := []int{7, 57, 107, 157}; !slices.Equal(failed, wantFailed) {
+			t.Errorf("%d workers: want errors for lines (%v), get (%v)", workers, wantFailed, failed)
+		}
+	}
+
+	// the caller stops receiving items after the first one: the workers
+	// blocked on sending items exit on cancel, which closes errs
+	ctx, cancel := context.WithCancel(context.Background())
+	items, errs := goaccessfmt.ParseConcurrent(ctx, conf, strings.NewReader(input.String()), 4)
+	<-items
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for range errs {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("errs not closed after cancel")
+	}
+	for range items {
+	}
+}
+
+func TestParseIterator(t *testing.T) {
+	conf, err := goaccessfmt.SetupConfig(goaccessfmt.Logs.Common, goaccessfmt.Dates.Apache, goaccessfmt.Times.Fmt24, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `114.5.1.4 - - [11/Jun/2023:11:23:45 +0000] "GET /a HTTP/1.1" 200 568
+invalid
+114.5.1.5 - - [11/Jun/2023:11:23:46 +0000] "POST /b HTTP/1.1" 404 12
+114.5.1.6 - - [11/Jun/2023:11:23:47 +0000] "GET /c HTTP/1.1" 200 1
+`
+	var hosts []string
+	errs := 0
+	for logitem, err := range goaccessfmt.Parse(conf, strings.NewReader(input)) {
+		if err != nil {
+			errs++
+			continue
+		}
+		hosts = append(hosts, logitem.Host)
+		if len(hosts) == 2 {
+			break
+		}
+	}
+	if errs != 1 {
+		t.Errorf("want (%v) errors, get (%v)", 1, errs)
+	}
+	want := []string{"114.5.1.4", "114.5.1.5"}
+	if !slices.Equal(hosts, want) {
+		t.Errorf("want (%v), get (%v)", want, hosts)
+	}
+
+	// a read error is yielded last
+	errRead := errors.New("read error")
+	var last error
+	n := 0
+	for _, err := range goaccessfmt.Parse(conf, io.MultiReader(strings.NewReader(input), iotest.ErrReader(errRead))) {
+		last = err
+		n++
+	}
+	if n != 5 || !errors.Is(last, errRead) {
+		t.Errorf("want 5 results ending with (%v), get %d ending with (%v)", errRead, n, last)
+	}
+}
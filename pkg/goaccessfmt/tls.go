@@ -0,0 +1,56 @@
+package goaccessfmt
+
+import (
+	"crypto/tls"
+	"strconv"
+)
+
+var tlsVersionNames = map[uint64]string{
+	0x0300: "SSL 3.0",
+	0x0301: "TLS 1.0",
+	0x0302: "TLS 1.1",
+	0x0303: "TLS 1.2",
+	0x0304: "TLS 1.3",
+}
+
+// parseTLSCode parses a numeric TLS code, given in decimal (e.g. "772") or
+// in hex (e.g. "0x0304").
+func parseTLSCode(s string) (uint64, bool) {
+	code, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// TLSVersionName returns the name of TLSType when it is a numeric TLS
+// version code, as logged by Caddy (e.g. 772 is "TLS 1.3").
+// Otherwise TLSType is returned as is.
+func (a GLogItem) TLSVersionName() string {
+	code, ok := parseTLSCode(a.TLSType)
+	if !ok {
+		return a.TLSType
+	}
+	if name, ok := tlsVersionNames[code]; ok {
+		return name
+	}
+	return a.TLSType
+}
+
+// TLSCipherName returns the IANA name of TLSCypher when it is a numeric
+// cipher suite code, as logged by Caddy (e.g. 4865 is
+// "TLS_AES_128_GCM_SHA256"). Otherwise TLSCypher is returned as is.
+func (a GLogItem) TLSCipherName() string {
+	code, ok := parseTLSCode(a.TLSCypher)
+	if !ok {
+		return a.TLSCypher
+	}
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if uint64(suite.ID) == code {
+				return suite.Name
+			}
+		}
+	}
+	return a.TLSCypher
+}
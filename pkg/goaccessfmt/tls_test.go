@@ -0,0 +1,28 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestTLSNames(t *testing.T) {
+	cases := []struct {
+		logitem goaccessfmt.GLogItem
+		version string
+		cipher  string
+	}{
+		{goaccessfmt.GLogItem{TLSType: "772", TLSCypher: "4865"}, "TLS 1.3", "TLS_AES_128_GCM_SHA256"},
+		{goaccessfmt.GLogItem{TLSType: "0x0303", TLSCypher: "49199"}, "TLS 1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		{goaccessfmt.GLogItem{TLSType: "TLSv1.3", TLSCypher: "TLS_AES_256_GCM_SHA384"}, "TLSv1.3", "TLS_AES_256_GCM_SHA384"},
+		{goaccessfmt.GLogItem{TLSType: "1", TLSCypher: "1"}, "1", "1"},
+	}
+	for _, c := range cases {
+		if name := c.logitem.TLSVersionName(); name != c.version {
+			t.Errorf("want (%v), get (%v)", c.version, name)
+		}
+		if name := c.logitem.TLSCipherName(); name != c.cipher {
+			t.Errorf("want (%v), get (%v)", c.cipher, name)
+		}
+	}
+}
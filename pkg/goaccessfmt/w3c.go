@@ -0,0 +1,55 @@
+package goaccessfmt
+
+import (
+	"errors"
+	"strings"
+)
+
+// w3cFields maps W3C Extended Log Format field names (lowercase) to
+// specifiers. Fields not in the map are skipped with "%^".
+var w3cFields = map[string]string{
+	"date":             "%d",
+	"time":             "%t",
+	"c-ip":             "%h",
+	"cs-username":      "%e",
+	"cs-host":          "%v",
+	"cs-method":        "%m",
+	"cs-uri-stem":      "%U",
+	"cs-uri-query":     "%q",
+	"cs-version":       "%H",
+	"sc-status":        "%s",
+	"sc-bytes":         "%b",
+	"time-taken":       "%L",
+	"cs(user-agent)":   "%u",
+	"cs(referer)":      "%R",
+	"cs(content-type)": "%M",
+}
+
+// FormatFromW3CFields builds the log format for a W3C Extended Log Format
+// (e.g. IIS) file from its "#Fields:" directive, so that columns can be in
+// any order. Unknown fields are skipped, and a field appearing more than
+// once is only captured the first time. The date and time formats are the
+// W3C ones.
+func FormatFromW3CFields(fieldsLine string) (logfmt, datefmt, timefmt string, err error) {
+	fieldsLine = strings.TrimSpace(fieldsLine)
+	rest, ok := strings.CutPrefix(fieldsLine, "#Fields:")
+	if !ok {
+		return "", "", "", errors.New("not a W3C #Fields: directive")
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", "", "", errors.New("no fields in W3C #Fields: directive")
+	}
+
+	specs := make([]string, len(fields))
+	seen := make(map[string]bool)
+	for i, field := range fields {
+		spec, ok := w3cFields[strings.ToLower(field)]
+		if !ok || seen[spec] {
+			spec = "%^"
+		}
+		seen[spec] = true
+		specs[i] = spec
+	}
+	return strings.Join(specs, " "), Dates.W3C, Times.Fmt24, nil
+}
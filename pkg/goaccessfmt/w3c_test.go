@@ -0,0 +1,49 @@
+package goaccessfmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taoky/goaccessfmt/pkg/goaccessfmt"
+)
+
+func TestFormatFromW3CFields(t *testing.T) {
+	fields := "#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status sc-bytes time-taken"
+	logfmt, datefmt, timefmt, err := goaccessfmt.FormatFromW3CFields(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "%d %t %^ %m %U %q %^ %e %h %u %R %s %^ %^ %b %L"; logfmt != want {
+		t.Errorf("want (%v), get (%v)", want, logfmt)
+	}
+
+	conf, err := goaccessfmt.SetupConfig(logfmt, datefmt, timefmt, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "2023-06-11 11:23:45 10.0.0.1 GET /default.htm a=1 80 alice 114.5.1.4 Mozilla/5.0 https://example.com/ 404 0 2 1234 15"
+	logitem, err := goaccessfmt.ParseLine(conf, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogitem := goaccessfmt.GLogItem{
+		Host:      "114.5.1.4",
+		Userid:    "alice",
+		Dt:        time.Date(2023, 6, 11, 11, 23, 45, 0, time.UTC),
+		Method:    "GET",
+		Req:       "/default.htm",
+		Qstr:      "a=1",
+		Status:    404,
+		RespSize:  1234,
+		ServeTime: 15000,
+		Ref:       "https://example.com/",
+		Agent:     "Mozilla/5.0",
+	}
+	if !logitem.Equal(expectedLogitem) {
+		t.Errorf("want (%v), get (%v)", expectedLogitem, logitem)
+	}
+
+	if _, _, _, err := goaccessfmt.FormatFromW3CFields("#Version: 1.0"); err == nil {
+		t.Error("want error for a line without #Fields:")
+	}
+}